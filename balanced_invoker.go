@@ -0,0 +1,372 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// BalanceStrategy decides endpoint to which BalancedInvoker sends message.
+type BalanceStrategy int
+
+const (
+	// BalanceRoundRobin selects endpoints in order.
+	BalanceRoundRobin BalanceStrategy = iota
+	// BalanceLeastInFlight selects endpoint which has least requests in flight.
+	BalanceLeastInFlight
+	// BalanceWeighted selects endpoints by smooth weighted round robin.
+	BalanceWeighted
+)
+
+// UnmarshalText parses strategy name: "round_robin", "least_in_flight" or "weighted".
+func (s *BalanceStrategy) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "round_robin":
+		*s = BalanceRoundRobin
+	case "least_in_flight":
+		*s = BalanceLeastInFlight
+	case "weighted":
+		*s = BalanceWeighted
+	default:
+		return fmt.Errorf("unknown balance strategy: %s", b)
+	}
+	return nil
+}
+
+// String returns strategy name.
+func (s BalanceStrategy) String() string {
+	switch s {
+	case BalanceLeastInFlight:
+		return "least_in_flight"
+	case BalanceWeighted:
+		return "weighted"
+	}
+	return "round_robin"
+}
+
+// Endpoint is destination of BalancedInvoker. Weight is used by BalanceWeighted, and 0 is treated as 1.
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// EndpointStatus shows current state of endpoint.
+type EndpointStatus struct {
+	URL                 string
+	Weight              int
+	Healthy             bool
+	EjectedUntil        time.Time
+	InFlight            int
+	ConsecutiveFailures int
+	TotalRequests       int64
+	TotalFailures       int64
+	LastError           error
+	LastCheckedAt       time.Time
+}
+
+// Ejected returns whether endpoint is ejected at now.
+func (s EndpointStatus) Ejected(now time.Time) bool {
+	return now.Before(s.EjectedUntil)
+}
+
+// EndpointReporter is implemented by Invoker which reports state of its endpoints.
+type EndpointReporter interface {
+	Strategy() BalanceStrategy
+	Endpoints() []EndpointStatus
+}
+
+type balancedEndpoint struct {
+	invoker       *HTTPInvoker
+	healthURL     string
+	status        EndpointStatus
+	checkFailures int
+	// currentWeight is used by smooth weighted round robin.
+	currentWeight int
+}
+
+func (e *balancedEndpoint) available(now time.Time) bool {
+	return e.status.Healthy && !e.status.Ejected(now)
+}
+
+// BalancedInvoker invokes worker process by HTTP POST request to one of endpoints.
+// Endpoint which fails consecutively is ejected for a while (outlier ejection),
+// and endpoint which fails health checks consecutively is not used until it passes health check.
+// When no endpoint is available, all endpoints are used.
+type BalancedInvoker struct {
+	strategy          BalanceStrategy
+	threshold         int
+	ejectionDuration  time.Duration
+	healthCheckPath   string
+	healthCheckPeriod time.Duration
+	httpOpts          []HTTPInvokerOption
+
+	mu        sync.Mutex
+	endpoints []*balancedEndpoint
+	next      int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ EndpointReporter = (*BalancedInvoker)(nil)
+
+// BalancedInvokerOption is an option for BalancedInvoker.
+type BalancedInvokerOption func(*BalancedInvoker)
+
+// BalancerStrategy sets strategy to select endpoint. As default, BalanceRoundRobin is used.
+func BalancerStrategy(s BalanceStrategy) BalancedInvokerOption {
+	return func(ivk *BalancedInvoker) {
+		ivk.strategy = s
+	}
+}
+
+// BalancerEjection sets count of consecutive failures to eject endpoint and duration of ejection.
+// threshold is also used for health checks. As default, 5 failures eject endpoint for 30 seconds.
+func BalancerEjection(threshold int, dur time.Duration) BalancedInvokerOption {
+	return func(ivk *BalancedInvoker) {
+		ivk.threshold = threshold
+		ivk.ejectionDuration = dur
+	}
+}
+
+// BalancerHealthCheck enables active health check by GET request to path of each endpoint per interval.
+// 2xx status means healthy.
+func BalancerHealthCheck(path string, interval time.Duration) BalancedInvokerOption {
+	return func(ivk *BalancedInvoker) {
+		ivk.healthCheckPath = path
+		ivk.healthCheckPeriod = interval
+	}
+}
+
+// BalancerHTTPOptions sets options of HTTPInvoker for each endpoint.
+func BalancerHTTPOptions(opts ...HTTPInvokerOption) BalancedInvokerOption {
+	return func(ivk *BalancedInvoker) {
+		ivk.httpOpts = append(ivk.httpOpts, opts...)
+	}
+}
+
+// NewBalancedInvoker returns BalancedInvoker instance. Health checks start immediately if it is enabled,
+// so Close must be called to stop them.
+func NewBalancedInvoker(endpoints []Endpoint, dur time.Duration, opts ...BalancedInvokerOption) (*BalancedInvoker, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("endpoints are required")
+	}
+	ivk := &BalancedInvoker{
+		threshold:        5,
+		ejectionDuration: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	if ivk.threshold <= 0 {
+		return nil, errors.New("threshold must be greater than 0")
+	}
+	for _, ep := range endpoints {
+		if ep.Weight < 0 {
+			return nil, fmt.Errorf("weight must not be negative: %s", ep.URL)
+		}
+		hi, err := NewHTTPInvoker(ep.URL, dur, ivk.httpOpts...)
+		if err != nil {
+			return nil, err
+		}
+		e := &balancedEndpoint{
+			invoker: hi,
+			status: EndpointStatus{
+				URL:     ep.URL,
+				Weight:  max(ep.Weight, 1),
+				Healthy: true,
+			},
+		}
+		if ivk.healthCheckPath != "" {
+			u, err := url.Parse(hi.url)
+			if err != nil {
+				return nil, err
+			}
+			u.Path, u.RawQuery = ivk.healthCheckPath, ""
+			e.healthURL = u.String()
+		}
+		ivk.endpoints = append(ivk.endpoints, e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ivk.cancel = cancel
+	if ivk.healthCheckPath != "" && ivk.healthCheckPeriod > 0 {
+		for _, e := range ivk.endpoints {
+			ivk.wg.Add(1)
+			go ivk.runHealthCheck(ctx, e)
+		}
+	}
+	return ivk, nil
+}
+
+// Invoke sends message to endpoint selected by strategy.
+func (ivk *BalancedInvoker) Invoke(ctx context.Context, q Message) error {
+	e := ivk.pick()
+	err := e.invoker.Invoke(ctx, q)
+	ivk.done(e, err)
+	return err
+}
+
+// InvokeBatch sends messages to endpoint selected by strategy.
+// BatchItemFailures is not counted as failure of endpoint.
+func (ivk *BalancedInvoker) InvokeBatch(ctx context.Context, msgs []Message) error {
+	e := ivk.pick()
+	err := e.invoker.InvokeBatch(ctx, msgs)
+	var failures BatchItemFailures
+	if errors.As(err, &failures) {
+		ivk.done(e, nil)
+	} else {
+		ivk.done(e, err)
+	}
+	return err
+}
+
+func (ivk *BalancedInvoker) pick() *balancedEndpoint {
+	ivk.mu.Lock()
+	defer ivk.mu.Unlock()
+
+	now := time.Now()
+	candidates := make([]*balancedEndpoint, 0, len(ivk.endpoints))
+	for _, e := range ivk.endpoints {
+		if e.available(now) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = ivk.endpoints
+	}
+
+	var picked *balancedEndpoint
+	switch ivk.strategy {
+	case BalanceLeastInFlight:
+		// start from next position not to concentrate on first endpoint when in-flight counts are the same.
+		for i := range candidates {
+			e := candidates[(ivk.next+i)%len(candidates)]
+			if picked == nil || e.status.InFlight < picked.status.InFlight {
+				picked = e
+			}
+		}
+		ivk.next++
+	case BalanceWeighted:
+		total := 0
+		for _, e := range candidates {
+			e.currentWeight += e.status.Weight
+			total += e.status.Weight
+			if picked == nil || e.currentWeight > picked.currentWeight {
+				picked = e
+			}
+		}
+		picked.currentWeight -= total
+	default:
+		picked = candidates[ivk.next%len(candidates)]
+		ivk.next++
+	}
+	picked.status.InFlight++
+	picked.status.TotalRequests++
+	return picked
+}
+
+func (ivk *BalancedInvoker) done(e *balancedEndpoint, err error) {
+	ivk.mu.Lock()
+	defer ivk.mu.Unlock()
+	e.status.InFlight--
+	if err == nil {
+		e.status.ConsecutiveFailures = 0
+		return
+	}
+	e.status.TotalFailures++
+	e.status.ConsecutiveFailures++
+	e.status.LastError = err
+	if e.status.ConsecutiveFailures >= ivk.threshold {
+		e.status.EjectedUntil = time.Now().Add(ivk.ejectionDuration)
+		e.status.ConsecutiveFailures = 0
+		getLogger().Warn("endpoint is ejected", "url", e.status.URL, "until", e.status.EjectedUntil, "error", err)
+	}
+}
+
+func (ivk *BalancedInvoker) runHealthCheck(ctx context.Context, e *balancedEndpoint) {
+	defer ivk.wg.Done()
+	ticker := time.NewTicker(ivk.healthCheckPeriod)
+	defer ticker.Stop()
+	for {
+		ivk.checkHealth(ctx, e)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ivk *BalancedInvoker) checkHealth(ctx context.Context, e *balancedEndpoint) {
+	err := e.invoker.get(ctx, e.healthURL)
+	if ctx.Err() != nil {
+		return
+	}
+
+	ivk.mu.Lock()
+	defer ivk.mu.Unlock()
+	e.status.LastCheckedAt = time.Now()
+	if err == nil {
+		if !e.status.Healthy {
+			getLogger().Info("endpoint becomes healthy", "url", e.status.URL)
+		}
+		e.checkFailures = 0
+		e.status.Healthy = true
+		return
+	}
+	e.checkFailures++
+	e.status.LastError = err
+	if e.status.Healthy && e.checkFailures >= ivk.threshold {
+		getLogger().Warn("endpoint becomes unhealthy", "url", e.status.URL, "error", err)
+		e.status.Healthy = false
+	}
+}
+
+// get sends GET request to rawurl by the same client and headers as invocation, and returns error unless 2xx status.
+func (ivk *HTTPInvoker) get(ctx context.Context, rawurl string) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = ivk.header.Clone()
+	resp, err := ivk.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("health check status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Strategy returns strategy to select endpoint.
+func (ivk *BalancedInvoker) Strategy() BalanceStrategy {
+	return ivk.strategy
+}
+
+// Endpoints returns current state of endpoints.
+func (ivk *BalancedInvoker) Endpoints() []EndpointStatus {
+	ivk.mu.Lock()
+	defer ivk.mu.Unlock()
+	statuses := make([]EndpointStatus, 0, len(ivk.endpoints))
+	for _, e := range ivk.endpoints {
+		statuses = append(statuses, e.status)
+	}
+	return statuses
+}
+
+// Close stops health checks.
+func (ivk *BalancedInvoker) Close() error {
+	ivk.cancel()
+	ivk.wg.Wait()
+	return nil
+}
@@ -0,0 +1,162 @@
+package sqsd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type balancedTestServer struct {
+	*httptest.Server
+	hits    atomic.Int64
+	status  atomic.Int64
+	health  atomic.Int64
+	release chan struct{}
+}
+
+func newBalancedTestServer(t *testing.T) *balancedTestServer {
+	s := &balancedTestServer{}
+	s.status.Store(http.StatusOK)
+	s.health.Store(http.StatusOK)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(int(s.health.Load()))
+			return
+		}
+		s.hits.Add(1)
+		if s.release != nil {
+			<-s.release
+		}
+		w.WriteHeader(int(s.status.Load()))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestBalancedInvokerStrategy(t *testing.T) {
+	_, err := NewBalancedInvoker(nil, time.Second)
+	assert.EqualError(t, err, "endpoints are required")
+
+	var s BalanceStrategy
+	assert.NoError(t, s.UnmarshalText([]byte("weighted")))
+	assert.Equal(t, BalanceWeighted, s)
+	assert.EqualError(t, s.UnmarshalText([]byte("random")), "unknown balance strategy: random")
+
+	for _, tt := range []struct {
+		strategy BalanceStrategy
+		weights  []int
+		expected []int64
+	}{
+		{strategy: BalanceRoundRobin, weights: []int{3, 1}, expected: []int64{4, 4}},
+		{strategy: BalanceWeighted, weights: []int{3, 1}, expected: []int64{6, 2}},
+		{strategy: BalanceWeighted, weights: []int{0, 0}, expected: []int64{4, 4}},
+	} {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			servers := []*balancedTestServer{newBalancedTestServer(t), newBalancedTestServer(t)}
+			var endpoints []Endpoint
+			for i, s := range servers {
+				endpoints = append(endpoints, Endpoint{URL: s.URL, Weight: tt.weights[i]})
+			}
+			ivk, err := NewBalancedInvoker(endpoints, time.Second, BalancerStrategy(tt.strategy))
+			assert.NoError(t, err)
+			t.Cleanup(func() { ivk.Close() })
+			for i := 0; i < 8; i++ {
+				assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+			}
+			for i, s := range servers {
+				assert.Equal(t, tt.expected[i], s.hits.Load())
+			}
+		})
+	}
+}
+
+func TestBalancedInvokerLeastInFlight(t *testing.T) {
+	busy := newBalancedTestServer(t)
+	busy.release = make(chan struct{})
+	idle := newBalancedTestServer(t)
+
+	ivk, err := NewBalancedInvoker([]Endpoint{{URL: busy.URL}, {URL: idle.URL}}, time.Second,
+		BalancerStrategy(BalanceLeastInFlight))
+	assert.NoError(t, err)
+	t.Cleanup(func() { ivk.Close() })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	}()
+	assert.Eventually(t, func() bool { return busy.hits.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:2", Payload: []byte(`{}`)}))
+	}
+	assert.Equal(t, int64(1), busy.hits.Load())
+	assert.Equal(t, int64(3), idle.hits.Load())
+	close(busy.release)
+	wg.Wait()
+}
+
+func TestBalancedInvokerEjection(t *testing.T) {
+	failing := newBalancedTestServer(t)
+	failing.status.Store(http.StatusInternalServerError)
+	ok := newBalancedTestServer(t)
+
+	ivk, err := NewBalancedInvoker([]Endpoint{{URL: failing.URL}, {URL: ok.URL}}, time.Second,
+		BalancerEjection(2, time.Hour))
+	assert.NoError(t, err)
+	t.Cleanup(func() { ivk.Close() })
+
+	for i := 0; i < 10; i++ {
+		_ = ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)})
+	}
+	assert.Equal(t, int64(2), failing.hits.Load())
+	assert.Equal(t, int64(8), ok.hits.Load())
+
+	statuses := ivk.Endpoints()
+	assert.True(t, statuses[0].Ejected(time.Now()))
+	assert.Equal(t, int64(2), statuses[0].TotalFailures)
+	assert.EqualError(t, statuses[0].LastError, "failure response: 500")
+	assert.False(t, statuses[1].Ejected(time.Now()))
+
+	// when all endpoints are unavailable, all endpoints are used.
+	ok.status.Store(http.StatusInternalServerError)
+	for i := 0; i < 4; i++ {
+		_ = ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)})
+	}
+	assert.Equal(t, int64(3), failing.hits.Load())
+	assert.Equal(t, int64(11), ok.hits.Load())
+}
+
+func TestBalancedInvokerHealthCheck(t *testing.T) {
+	unhealthy := newBalancedTestServer(t)
+	unhealthy.health.Store(http.StatusServiceUnavailable)
+	healthy := newBalancedTestServer(t)
+
+	ivk, err := NewBalancedInvoker([]Endpoint{{URL: unhealthy.URL + "/jobs"}, {URL: healthy.URL + "/jobs"}}, time.Second,
+		BalancerEjection(1, time.Hour),
+		BalancerHealthCheck("/health", 10*time.Millisecond))
+	assert.NoError(t, err)
+	t.Cleanup(func() { ivk.Close() })
+
+	assert.Eventually(t, func() bool {
+		return !ivk.Endpoints()[0].Healthy
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	}
+	assert.Equal(t, int64(0), unhealthy.hits.Load())
+	assert.Equal(t, int64(4), healthy.hits.Load())
+
+	unhealthy.health.Store(http.StatusOK)
+	assert.Eventually(t, func() bool {
+		return ivk.Endpoints()[0].Healthy
+	}, time.Second, 10*time.Millisecond)
+	assert.NotZero(t, ivk.Endpoints()[0].LastCheckedAt)
+}
@@ -0,0 +1,173 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// BatchInvoker invokes worker process with multiple messages at once.
+//
+// InvokeBatch returns nil when all messages succeed, and BatchItemFailures when some of messages fail.
+// Other errors mean that all messages fail.
+type BatchInvoker interface {
+	InvokeBatch(context.Context, []Message) error
+}
+
+// BatchItemFailures is IDs of messages which fail in batch. Other messages in batch are treated as success.
+type BatchItemFailures []string
+
+// Error implements error interface.
+func (f BatchItemFailures) Error() string {
+	return fmt.Sprintf("%d messages in batch failed", len(f))
+}
+
+func startBatchWorker(ctx context.Context, ivk BatchInvoker, size int, window time.Duration, broker chan Message, rm remover) *worker {
+	capacity := cap(broker)
+	w := &worker{
+		semaphore: semaphore.NewWeighted(int64(capacity)),
+	}
+	batches := make(chan []Message)
+	go collectBatches(ctx, broker, batches, size, window)
+	for i := 0; i < capacity; i++ {
+		go w.RunForBatch(ctx, ivk, batches, rm)
+	}
+	return w
+}
+
+// collectBatches sends messages to batches when size messages are collected,
+// or window passes after first message of batch is received.
+// When broker is closed, collected messages are sent as the last batch.
+func collectBatches(ctx context.Context, broker chan Message, batches chan []Message, size int, window time.Duration) {
+	for {
+		var batch []Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-broker:
+			if !ok {
+				return
+			}
+			batch = append(batch, msg)
+		}
+		timer := time.NewTimer(window)
+		closed := false
+	collect:
+		for len(batch) < size {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case msg, ok := <-broker:
+				if !ok {
+					closed = true
+					break collect
+				}
+				batch = append(batch, msg)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case batches <- batch:
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+func (w *worker) RunForBatch(ctx context.Context, ivk BatchInvoker, batches chan []Message, rm remover) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msgs := <-batches:
+			w.wrappedProcessBatch(ivk, msgs, rm)
+		}
+	}
+}
+
+func (w *worker) wrappedProcessBatch(ivk BatchInvoker, msgs []Message, rm remover) {
+	ctx := context.Background()
+
+	// error never be returned because always this method receives new context object.
+	_ = w.semaphore.Acquire(ctx, 1)
+	defer w.semaphore.Release(1)
+
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+		w.workings.Store(msg.ID, &Task{
+			Id:        msg.ID,
+			Receipt:   msg.Receipt,
+			StartedAt: timestamppb.New(time.Now()),
+		})
+	}
+	defer func() {
+		for _, id := range ids {
+			w.workings.Delete(id)
+		}
+	}()
+
+	logger := getLogger().With("message_ids", ids)
+	logger.Debug("start to invoke batch.")
+	err := recoverPanic(func() error {
+		return ivk.InvokeBatch(ctx, msgs)
+	})
+	var failures BatchItemFailures
+	var panicErr *PanicError
+	switch {
+	case err == nil:
+		logger.Debug("succeeded to invoke batch.")
+		w.removeAll(ctx, msgs, nil, rm)
+	case errors.As(err, &failures):
+		logger.Info("some messages in batch failed", "failed_ids", []string(failures))
+		failed := make(map[string]struct{}, len(failures))
+		for _, id := range failures {
+			failed[id] = struct{}{}
+		}
+		w.removeAll(ctx, msgs, failed, rm)
+	case errors.Is(err, ErrRetainMessage):
+		logger.Info("received messages should be retained")
+		w.unlockAll(ctx, msgs, rm)
+	case errors.As(err, &panicErr):
+		w.recordPanic(msgs[0], panicErr)
+		logger.Error("invoker panics. all messages in batch are treated as failure.",
+			"panic", fmt.Sprint(panicErr.Value),
+			"stack", string(panicErr.Stack))
+		w.unlockAll(ctx, msgs, rm)
+	default:
+		logger.Error("failed to invoke batch.", "error", err)
+		w.unlockAll(ctx, msgs, rm)
+	}
+}
+
+// unlockAll releases dedup keys of messages kept in queue, so that each of them is retried after its visibility timeout.
+func (w *worker) unlockAll(ctx context.Context, msgs []Message, rm remover) {
+	for _, msg := range msgs {
+		rm.unlock(ctx, msg)
+	}
+}
+
+// removeAll removes messages except for failed ones.
+// failed messages are kept in queue and their dedup keys are released,
+// so that each of them is received again after its visibility timeout and retried individually.
+func (w *worker) removeAll(ctx context.Context, msgs []Message, failed map[string]struct{}, rm remover) {
+	for _, msg := range msgs {
+		if _, ok := failed[msg.ID]; ok {
+			rm.unlock(ctx, msg)
+			continue
+		}
+		if err := rm.remove(ctx, msg); err != nil {
+			getLogger().Warn("failed to remove message", "message_id", msg.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/taiyoh/sqsd/locker"
+	memorylocker "github.com/taiyoh/sqsd/locker/memory"
+)
+
+type testBatchInvoker func(context.Context, []Message) error
+
+func (f testBatchInvoker) InvokeBatch(ctx context.Context, msgs []Message) error {
+	return f(ctx, msgs)
+}
+
+func TestCollectBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	broker := make(chan Message, 10)
+	batches := make(chan []Message)
+	go collectBatches(ctx, broker, batches, 3, 50*time.Millisecond)
+
+	for i := 1; i <= 4; i++ {
+		broker <- Message{ID: fmt.Sprintf("id:%d", i)}
+	}
+	// first batch is sent by size.
+	assert.Len(t, <-batches, 3)
+
+	// second batch is sent by window.
+	started := time.Now()
+	batch := <-batches
+	assert.Len(t, batch, 1)
+	assert.Equal(t, "id:4", batch[0].ID)
+	assert.GreaterOrEqual(t, time.Since(started), 40*time.Millisecond)
+}
+
+func TestCollectBatchesClosed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	broker := make(chan Message, 10)
+	batches := make(chan []Message, 10)
+	done := make(chan struct{})
+	go func() {
+		collectBatches(ctx, broker, batches, 3, time.Hour)
+		close(done)
+	}()
+
+	broker <- Message{ID: "id:1"}
+	close(broker)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("collectBatches doesn't return after broker is closed")
+	}
+	// collected message is flushed, and no empty message is batched.
+	assert.Equal(t, []Message{{ID: "id:1"}}, <-batches)
+	assert.Empty(t, batches)
+}
+
+func TestBatchWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	results := make(chan error, 1)
+	invoked := make(chan []Message, 1)
+	ivk := testBatchInvoker(func(ctx context.Context, msgs []Message) error {
+		invoked <- msgs
+		err := <-results
+		if err != nil && err.Error() == "panic" {
+			panic("boom")
+		}
+		return err
+	})
+	rm := &testRemover{
+		removed:     make(chan string, 10),
+		retried:     make(chan time.Duration, 10),
+		deadLetters: make(chan string, 10),
+	}
+	broker := make(chan Message, 3)
+	w := startBatchWorker(ctx, ivk, 3, time.Hour, broker, rm)
+
+	removed := func(n int) []string {
+		var ids []string
+		for i := 0; i < n; i++ {
+			ids = append(ids, <-rm.removed)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	for _, tt := range []struct {
+		label   string
+		err     error
+		removed []string
+	}{
+		{label: "success", err: nil, removed: []string{"id:1", "id:2", "id:3"}},
+		{label: "partial failure", err: BatchItemFailures{"id:2"}, removed: []string{"id:1", "id:3"}},
+		{label: "whole failure", err: errors.New("failure")},
+		{label: "retain", err: ErrRetainMessage},
+		{label: "panic", err: errors.New("panic")},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			for i := 1; i <= 3; i++ {
+				broker <- Message{ID: fmt.Sprintf("id:%d", i)}
+			}
+			msgs := <-invoked
+			assert.Len(t, msgs, 3)
+			assert.Len(t, w.CurrentWorkings(ctx), 3)
+			results <- tt.err
+			assert.Equal(t, tt.removed, removed(len(tt.removed)))
+			assert.Eventually(t, func() bool {
+				return len(w.CurrentWorkings(ctx)) == 0
+			}, time.Second, 10*time.Millisecond)
+			assert.Empty(t, rm.removed)
+		})
+	}
+	assert.Equal(t, int64(1), w.PanicStats().Count)
+}
+
+func TestBatchWorkerRedeliverFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	invoked := make(chan []string, 2)
+	ivk := testBatchInvoker(func(ctx context.Context, msgs []Message) error {
+		var ids []string
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+		invoked <- ids
+		if len(msgs) > 1 {
+			return BatchItemFailures{"id:2"}
+		}
+		return nil
+	})
+	l := memorylocker.New()
+	broker := make(chan Message, 3)
+	w := startBatchWorker(ctx, ivk, 3, 10*time.Millisecond, broker, &Gateway{locker: l})
+
+	for i := 1; i <= 3; i++ {
+		msg := Message{ID: fmt.Sprintf("id:%d", i), DedupKey: fmt.Sprintf("key:%d", i)}
+		assert.NoError(t, l.Lock(ctx, msg.DedupKey))
+		broker <- msg
+	}
+	assert.Equal(t, []string{"id:1", "id:2", "id:3"}, <-invoked)
+	assert.Eventually(t, func() bool {
+		return len(w.CurrentWorkings(ctx)) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// failed message received again is locked and processed, as well as Gateway does.
+	assert.ErrorIs(t, l.Lock(ctx, "key:1"), locker.ErrQueueExists)
+	assert.NoError(t, l.Lock(ctx, "key:2"), "dedup key of failed message is released")
+	broker <- Message{ID: "id:2", DedupKey: "key:2"}
+	assert.Equal(t, []string{"id:2"}, <-invoked)
+}
+
+func TestBatchWorkerUnlockWholeFailure(t *testing.T) {
+	for label, fail := range map[string]func() error{
+		"failure": func() error { return errors.New("failure") },
+		"retain":  func() error { return ErrRetainMessage },
+		"panic":   func() error { panic("boom") },
+	} {
+		t.Run(label, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			invoked := make(chan struct{}, 1)
+			ivk := testBatchInvoker(func(ctx context.Context, msgs []Message) error {
+				invoked <- struct{}{}
+				return fail()
+			})
+			l := memorylocker.New()
+			broker := make(chan Message, 2)
+			w := startBatchWorker(ctx, ivk, 2, time.Hour, broker, &Gateway{locker: l})
+
+			for i := 1; i <= 2; i++ {
+				msg := Message{ID: fmt.Sprintf("id:%d", i), DedupKey: fmt.Sprintf("key:%d", i)}
+				assert.NoError(t, l.Lock(ctx, msg.DedupKey))
+				broker <- msg
+			}
+			<-invoked
+			assert.Eventually(t, func() bool {
+				return len(w.CurrentWorkings(ctx)) == 0
+			}, time.Second, 10*time.Millisecond)
+			assert.NoError(t, l.Lock(ctx, "key:1"), "dedup keys of all messages are released")
+			assert.NoError(t, l.Lock(ctx, "key:2"))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+)
+
+// CanaryStats shows weight and results of CanaryInvoker.
+type CanaryStats struct {
+	Weight         int
+	StableRequests int64
+	StableFailures int64
+	CanaryRequests int64
+	CanaryFailures int64
+}
+
+// CanaryController is implemented by Invoker whose canary weight is adjustable at runtime.
+type CanaryController interface {
+	CanaryStats() CanaryStats
+	SetCanaryWeight(weight int) error
+}
+
+// CanaryInvoker sends weighted percentage of messages to canary invoker, and others to stable invoker.
+// Result of invocation is taken from the chosen invoker.
+type CanaryInvoker struct {
+	stable Invoker
+	canary Invoker
+	weight atomic.Int32
+
+	stableRequests atomic.Int64
+	stableFailures atomic.Int64
+	canaryRequests atomic.Int64
+	canaryFailures atomic.Int64
+}
+
+var _ CanaryController = (*CanaryInvoker)(nil)
+
+// NewCanaryInvoker returns CanaryInvoker instance. weight is percentage of messages sent to canary, from 0 to 100.
+func NewCanaryInvoker(stable, canary Invoker, weight int) (*CanaryInvoker, error) {
+	if stable == nil || canary == nil {
+		return nil, errors.New("stable and canary invokers are required")
+	}
+	if err := validateCanaryWeight(weight); err != nil {
+		return nil, err
+	}
+	ivk := &CanaryInvoker{stable: stable, canary: canary}
+	ivk.weight.Store(int32(weight))
+	return ivk, nil
+}
+
+func validateCanaryWeight(weight int) error {
+	if weight < 0 || weight > 100 {
+		return errors.New("canary weight must be between 0 and 100")
+	}
+	return nil
+}
+
+// Invoke sends message to canary or stable by weight.
+func (ivk *CanaryInvoker) Invoke(ctx context.Context, q Message) error {
+	target, requests, failures := ivk.stable, &ivk.stableRequests, &ivk.stableFailures
+	if w := int(ivk.weight.Load()); w > 0 && rand.Intn(100) < w {
+		target, requests, failures = ivk.canary, &ivk.canaryRequests, &ivk.canaryFailures
+		getLogger().Debug("message is sent to canary", "message_id", q.ID)
+	}
+	requests.Add(1)
+	err := target.Invoke(ctx, q)
+	if outcomeOf(err) != "success" && outcomeOf(err) != "retain" {
+		failures.Add(1)
+	}
+	return err
+}
+
+// SetCanaryWeight changes percentage of messages sent to canary, from 0 to 100.
+func (ivk *CanaryInvoker) SetCanaryWeight(weight int) error {
+	if err := validateCanaryWeight(weight); err != nil {
+		return err
+	}
+	if old := ivk.weight.Swap(int32(weight)); int(old) != weight {
+		getLogger().Info("canary weight is changed", "from", old, "to", weight)
+	}
+	return nil
+}
+
+// CanaryStats returns current weight and results.
+func (ivk *CanaryInvoker) CanaryStats() CanaryStats {
+	return CanaryStats{
+		Weight:         int(ivk.weight.Load()),
+		StableRequests: ivk.stableRequests.Load(),
+		StableFailures: ivk.stableFailures.Load(),
+		CanaryRequests: ivk.canaryRequests.Load(),
+		CanaryFailures: ivk.canaryFailures.Load(),
+	}
+}
+
+// Unwrap returns stable and canary invokers.
+func (ivk *CanaryInvoker) Unwrap() []Invoker {
+	return []Invoker{ivk.stable, ivk.canary}
+}
+
+// Close closes stable and canary invokers which implement io.Closer.
+func (ivk *CanaryInvoker) Close() error {
+	return closeInvokers(ivk.stable, ivk.canary)
+}
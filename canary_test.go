@@ -0,0 +1,57 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanaryInvoker(t *testing.T) {
+	ctx := context.Background()
+	stable := InvokerFunc(func(ctx context.Context, q Message) error { return nil })
+	canary := InvokerFunc(func(ctx context.Context, q Message) error { return errors.New("canary failure") })
+
+	ivk, err := NewCanaryInvoker(stable, canary, 0)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id"}))
+	}
+
+	assert.NoError(t, ivk.SetCanaryWeight(100))
+	for i := 0; i < 5; i++ {
+		assert.EqualError(t, ivk.Invoke(ctx, Message{ID: "id"}), "canary failure", "result is taken from canary")
+	}
+	assert.Equal(t, CanaryStats{
+		Weight:         100,
+		StableRequests: 10,
+		CanaryRequests: 5,
+		CanaryFailures: 5,
+	}, ivk.CanaryStats())
+
+	assert.NoError(t, ivk.SetCanaryWeight(50))
+	for i := 0; i < 200; i++ {
+		_ = ivk.Invoke(ctx, Message{ID: "id"})
+	}
+	stats := ivk.CanaryStats()
+	assert.Greater(t, stats.StableRequests, int64(10))
+	assert.Greater(t, stats.CanaryRequests, int64(5))
+	assert.Equal(t, int64(215), stats.StableRequests+stats.CanaryRequests)
+
+	assert.Error(t, ivk.SetCanaryWeight(-1))
+	assert.Error(t, ivk.SetCanaryWeight(101))
+	assert.Equal(t, 50, ivk.CanaryStats().Weight)
+
+	_, err = NewCanaryInvoker(stable, canary, 200)
+	assert.Error(t, err)
+}
+
+func TestCanaryInvokerClose(t *testing.T) {
+	stable, canary := &closableInvoker{}, &closableInvoker{}
+	ivk, err := NewCanaryInvoker(stable, canary, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, ivk.Close())
+	assert.True(t, stable.closed)
+	assert.True(t, canary.closed)
+}
@@ -0,0 +1,178 @@
+package sqsd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CloudEventsMode is content mode of CloudEvents HTTP binding.
+// see https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/http-protocol-binding.md
+type CloudEventsMode int
+
+const (
+	// CloudEventsBinary sends message body as request body, and context attributes as ce-* headers.
+	CloudEventsBinary CloudEventsMode = iota + 1
+	// CloudEventsStructured sends whole event as JSON request body.
+	CloudEventsStructured
+)
+
+// UnmarshalText parses mode name: "binary" or "structured".
+func (m *CloudEventsMode) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "binary":
+		*m = CloudEventsBinary
+	case "structured":
+		*m = CloudEventsStructured
+	default:
+		return fmt.Errorf("unknown cloudevents mode: %s", b)
+	}
+	return nil
+}
+
+// String returns mode name.
+func (m CloudEventsMode) String() string {
+	if m == CloudEventsStructured {
+		return "structured"
+	}
+	return "binary"
+}
+
+// DefaultCloudEventType is type of event whose message has no ce-type attribute.
+const DefaultCloudEventType = "com.amazonaws.sqs.message"
+
+const cloudEventsContentType = "application/cloudevents+json; charset=UTF-8"
+
+// cloudEventAttributePrefix is prefix of message attributes which are context attributes of event.
+const cloudEventAttributePrefix = "ce-"
+
+type cloudEvents struct {
+	mode   CloudEventsMode
+	source string
+}
+
+// HTTPCloudEvents sends message as CloudEvent by mode. source is "source" attribute of event, such as queue ARN.
+// Context attributes are taken from message:
+//
+//   - id: message ID
+//   - type: "ce-type" message attribute, or DefaultCloudEventType
+//   - time: "ce-time" message attribute, or SentTimestamp
+//
+// Other message attributes prefixed with "ce-" are sent as extension attributes.
+// Message body which is already a CloudEvent in structured mode is sent as it is.
+// It is not used by batch invocation.
+func HTTPCloudEvents(mode CloudEventsMode, source string) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.cloudEvents = &cloudEvents{mode: mode, source: source}
+	}
+}
+
+// encode returns request body and headers which override headers of invocation.
+func (ce *cloudEvents) encode(q Message) ([]byte, http.Header, error) {
+	h := http.Header{}
+	if isStructuredCloudEvent(q.Payload) {
+		h.Set("Content-Type", cloudEventsContentType)
+		return q.Payload, h, nil
+	}
+	attrs := ce.attributes(q)
+	if ce.mode == CloudEventsStructured {
+		body, err := structuredCloudEvent(attrs, q.Payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.Set("Content-Type", cloudEventsContentType)
+		return body, h, nil
+	}
+	h.Set("Content-Type", contentType(q))
+	for k, v := range attrs {
+		if k == "datacontenttype" {
+			h.Set("Content-Type", v)
+			continue
+		}
+		h.Set(cloudEventAttributePrefix+k, encodeCloudEventHeader(v))
+	}
+	return q.Payload, h, nil
+}
+
+// attributes returns context attributes of event for message.
+func (ce *cloudEvents) attributes(q Message) map[string]string {
+	attrs := map[string]string{}
+	for k, v := range q.Attributes {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), cloudEventAttributePrefix); ok && name != "" {
+			attrs[name] = v
+		}
+	}
+	attrs["specversion"] = "1.0"
+	attrs["id"] = q.ID
+	attrs["source"] = ce.source
+	if _, ok := attrs["type"]; !ok {
+		attrs["type"] = DefaultCloudEventType
+	}
+	if _, ok := attrs["time"]; !ok && !q.SentAt.IsZero() {
+		attrs["time"] = q.SentAt.UTC().Format(time.RFC3339Nano)
+	}
+	return attrs
+}
+
+// isStructuredCloudEvent reports whether payload is a CloudEvent in structured mode.
+func isStructuredCloudEvent(payload []byte) bool {
+	var event struct {
+		SpecVersion string `json:"specversion"`
+		ID          string `json:"id"`
+		Source      string `json:"source"`
+		Type        string `json:"type"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return false
+	}
+	return event.SpecVersion != "" && event.ID != "" && event.Source != "" && event.Type != ""
+}
+
+// structuredCloudEvent returns event in JSON format.
+// payload is embedded as JSON if it is valid JSON, as string if it is text, otherwise as base64.
+func structuredCloudEvent(attrs map[string]string, payload []byte) ([]byte, error) {
+	event := make(map[string]any, len(attrs)+1)
+	for k, v := range attrs {
+		event[k] = v
+	}
+	dataContentType, ok := attrs["datacontenttype"]
+	isJSON := json.Valid(payload) && (!ok || strings.Contains(dataContentType, "json"))
+	isText := utf8.Valid(payload)
+	switch {
+	case isJSON:
+		event["data"] = json.RawMessage(payload)
+	case isText:
+		event["data"] = string(payload)
+	default:
+		event["data_base64"] = base64.StdEncoding.EncodeToString(payload)
+	}
+	if !ok {
+		switch {
+		case isJSON:
+			event["datacontenttype"] = "application/json"
+		case isText:
+			event["datacontenttype"] = "text/plain; charset=utf-8"
+		default:
+			event["datacontenttype"] = "application/octet-stream"
+		}
+	}
+	return json.Marshal(event)
+}
+
+// encodeCloudEventHeader percent-encodes characters which must not appear in header value of binary mode,
+// which are space, double quote, percent and characters outside printable ASCII.
+func encodeCloudEventHeader(v string) string {
+	var b strings.Builder
+	for _, c := range []byte(v) {
+		if c > ' ' && c < 0x7f && c != '"' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cloudEventsRequest struct {
+	header http.Header
+	body   string
+}
+
+func newCloudEventsServer(t *testing.T) (string, <-chan cloudEventsRequest) {
+	reqCh := make(chan cloudEventsRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		reqCh <- cloudEventsRequest{header: r.Header, body: string(b)}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, reqCh
+}
+
+const testQueueARN = "arn:aws:sqs:ap-northeast-1:123456789012:jobs"
+
+var testSentAt = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func TestHTTPInvokerCloudEventsBinary(t *testing.T) {
+	url, reqCh := newCloudEventsServer(t)
+	ivk, err := NewHTTPInvoker(url, time.Second, HTTPCloudEvents(CloudEventsBinary, testQueueARN))
+	assert.NoError(t, err)
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{
+		ID:         "id:1",
+		Payload:    []byte(`{"hello":"world"}`),
+		SentAt:     testSentAt,
+		Attributes: map[string]string{"ce-type": "com.example.order.created", "ce-subject": "order 1", "Other": "x"},
+	}))
+	req := <-reqCh
+	assert.Equal(t, `{"hello":"world"}`, req.body)
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, "1.0", req.header.Get("ce-specversion"))
+	assert.Equal(t, "id:1", req.header.Get("ce-id"))
+	assert.Equal(t, testQueueARN, req.header.Get("ce-source"))
+	assert.Equal(t, "com.example.order.created", req.header.Get("ce-type"))
+	assert.Equal(t, "2023-10-01T12:00:00Z", req.header.Get("ce-time"))
+	assert.Equal(t, "order%201", req.header.Get("ce-subject"))
+	assert.Empty(t, req.header.Get("ce-other"))
+	assert.Equal(t, "id:1", req.header.Get("X_AWS_SQSD_MSGID"))
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{
+		ID:         "id:2",
+		Payload:    []byte(`hello`),
+		Attributes: map[string]string{"ce-time": "2023-10-02T00:00:00Z", "ce-datacontenttype": "text/plain"},
+	}))
+	req = <-reqCh
+	assert.Equal(t, "text/plain", req.header.Get("Content-Type"))
+	assert.Equal(t, DefaultCloudEventType, req.header.Get("ce-type"))
+	assert.Equal(t, "2023-10-02T00:00:00Z", req.header.Get("ce-time"))
+	assert.Empty(t, req.header.Get("ce-datacontenttype"))
+}
+
+func TestHTTPInvokerCloudEventsStructured(t *testing.T) {
+	url, reqCh := newCloudEventsServer(t)
+	ivk, err := NewHTTPInvoker(url, time.Second, HTTPCloudEvents(CloudEventsStructured, testQueueARN))
+	assert.NoError(t, err)
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{
+		ID:         "id:1",
+		Payload:    []byte(`{"hello":"world"}`),
+		SentAt:     testSentAt,
+		Attributes: map[string]string{"ce-type": "com.example.order.created"},
+	}))
+	req := <-reqCh
+	assert.Equal(t, cloudEventsContentType, req.header.Get("Content-Type"))
+	assert.Empty(t, req.header.Get("ce-id"))
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "id:1",
+		"source": "`+testQueueARN+`",
+		"type": "com.example.order.created",
+		"time": "2023-10-01T12:00:00Z",
+		"datacontenttype": "application/json",
+		"data": {"hello":"world"}
+	}`, req.body)
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:2", Payload: []byte(`hello`)}))
+	req = <-reqCh
+	var event map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(req.body), &event))
+	assert.Equal(t, "hello", event["data"])
+	assert.Equal(t, "text/plain; charset=utf-8", event["datacontenttype"])
+	assert.NotContains(t, event, "time")
+}
+
+func TestHTTPInvokerCloudEventsPassThrough(t *testing.T) {
+	event := `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"com.example.order.created","data":{"id":1}}`
+	for _, mode := range []CloudEventsMode{CloudEventsBinary, CloudEventsStructured} {
+		url, reqCh := newCloudEventsServer(t)
+		ivk, err := NewHTTPInvoker(url, time.Second, HTTPCloudEvents(mode, testQueueARN))
+		assert.NoError(t, err)
+
+		assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(event)}))
+		req := <-reqCh
+		assert.Equal(t, event, req.body, mode.String())
+		assert.Equal(t, cloudEventsContentType, req.header.Get("Content-Type"))
+		assert.Empty(t, req.header.Get("ce-id"))
+	}
+}
+
+func TestCloudEventsMode(t *testing.T) {
+	var m CloudEventsMode
+	assert.NoError(t, m.UnmarshalText([]byte("structured")))
+	assert.Equal(t, CloudEventsStructured, m)
+	assert.Equal(t, "structured", m.String())
+	assert.NoError(t, m.UnmarshalText([]byte("binary")))
+	assert.Equal(t, CloudEventsBinary, m)
+	assert.EqualError(t, m.UnmarshalText([]byte("batched")), "unknown cloudevents mode: batched")
+
+	assert.Equal(t, "a%20b%22%25%C3%A9", encodeCloudEventHeader(`a b"%é`))
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	sqsd "github.com/taiyoh/sqsd"
+)
+
+const adminUsage = `usage: sqsd admin [-addr host:port] [-timeout 10s] <command> [args]
+
+commands:
+  locks list [-offset 0] [-limit 100]  list keys held by locker
+  locks get <key>                      show a key held by locker
+  locks release <key>                  release a key, so that its message can be processed again
+  locker health                        show health of locker backend
+  unlocker status                      show result of sweeps by unlocker
+  invoker endpoints                    show state of endpoints of balanced invoker
+  worker status                        show panics and timeouts of worker
+  canary status                        show weight and results of canary invoker
+  canary set <weight>                  change percentage of messages sent to canary (0-100)
+`
+
+var errAdminUsage = errors.New("invalid arguments")
+
+// runAdmin runs administration subcommand against monitoring gRPC server.
+func runAdmin(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() { fmt.Fprint(w, adminUsage) }
+	addr := fs.String("addr", "localhost:6969", "monitoring gRPC server address")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errAdminUsage
+	}
+
+	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := sqsd.NewMonitoringServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	switch cmd, rest := fs.Arg(0)+" "+fs.Arg(1), fs.Args()[2:]; cmd {
+	case "locks list":
+		return adminListLocks(ctx, client, rest, w)
+	case "locks get":
+		if len(rest) != 1 {
+			fs.Usage()
+			return errAdminUsage
+		}
+		resp, err := client.GetLock(ctx, &sqsd.GetLockRequest{Key: rest[0]})
+		if err != nil {
+			return err
+		}
+		return writeLocks(w, resp.GetLock())
+	case "locks release":
+		if len(rest) != 1 {
+			fs.Usage()
+			return errAdminUsage
+		}
+		if _, err := client.ReleaseLock(ctx, &sqsd.ReleaseLockRequest{Key: rest[0]}); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "released: %s\n", rest[0])
+		return nil
+	case "locker health":
+		resp, err := client.LockerHealth(ctx, &sqsd.LockerHealthRequest{})
+		if err != nil {
+			return err
+		}
+		return writeLockerHealth(w, resp)
+	case "unlocker status":
+		resp, err := client.UnlockerStatus(ctx, &sqsd.UnlockerStatusRequest{})
+		if err != nil {
+			return err
+		}
+		return writeUnlockerStatus(w, resp)
+	case "invoker endpoints":
+		resp, err := client.InvokerEndpoints(ctx, &sqsd.InvokerEndpointsRequest{})
+		if err != nil {
+			return err
+		}
+		return writeInvokerEndpoints(w, resp)
+	case "worker status":
+		resp, err := client.WorkerStatus(ctx, &sqsd.WorkerStatusRequest{})
+		if err != nil {
+			return err
+		}
+		return writeWorkerStatus(w, resp)
+	case "canary status":
+		resp, err := client.Canary(ctx, &sqsd.CanaryRequest{})
+		if err != nil {
+			return err
+		}
+		return writeCanary(w, resp)
+	case "canary set":
+		if len(rest) != 1 {
+			fs.Usage()
+			return errAdminUsage
+		}
+		weight, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			fs.Usage()
+			return errAdminUsage
+		}
+		resp, err := client.SetCanaryWeight(ctx, &sqsd.SetCanaryWeightRequest{Weight: weight})
+		if err != nil {
+			return err
+		}
+		return writeCanary(w, resp)
+	}
+	fs.Usage()
+	return errAdminUsage
+}
+
+func adminListLocks(ctx context.Context, client sqsd.MonitoringServiceClient, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("locks list", flag.ContinueOnError)
+	fs.SetOutput(w)
+	offset := fs.Int64("offset", 0, "offset of locks")
+	limit := fs.Int64("limit", 100, "max count of locks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	resp, err := client.ListLocks(ctx, &sqsd.ListLocksRequest{Offset: *offset, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if err := writeLocks(w, resp.GetLocks()...); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "total: %d\n", resp.GetTotal())
+	return nil
+}
+
+func writeLocks(w io.Writer, locks ...*sqsd.LockEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tLOCKED_AT")
+	for _, l := range locks {
+		fmt.Fprintf(tw, "%s\t%s\n", l.GetKey(), l.GetLockedAt().AsTime().Format(time.RFC3339Nano))
+	}
+	return tw.Flush()
+}
+
+func writeLockerHealth(w io.Writer, resp *sqsd.LockerHealthResponse) error {
+	if !resp.GetReported() {
+		_, err := fmt.Fprintln(w, "locker health is not reported")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "state\t%s\n", resp.GetState())
+	fmt.Fprintf(tw, "consecutive_failures\t%d\n", resp.GetConsecutiveFailures())
+	fmt.Fprintf(tw, "last_error\t%s\n", resp.GetLastError())
+	if t := resp.GetLastFailureAt(); t != nil {
+		fmt.Fprintf(tw, "last_failure_at\t%s\n", t.AsTime().Format(time.RFC3339Nano))
+	}
+	if t := resp.GetOpenedAt(); t != nil {
+		fmt.Fprintf(tw, "opened_at\t%s\n", t.AsTime().Format(time.RFC3339Nano))
+	}
+	return tw.Flush()
+}
+
+func writeUnlockerStatus(w io.Writer, resp *sqsd.UnlockerStatusResponse) error {
+	if !resp.GetRunning() {
+		_, err := fmt.Fprintln(w, "unlocker is not running")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if t := resp.GetLastSweepAt(); t != nil {
+		fmt.Fprintf(tw, "last_sweep_at\t%s\n", t.AsTime().Format(time.RFC3339Nano))
+	}
+	fmt.Fprintf(tw, "last_removed\t%d\n", resp.GetLastRemoved())
+	fmt.Fprintf(tw, "last_duration\t%s\n", resp.GetLastDuration().AsDuration())
+	fmt.Fprintf(tw, "total_removed\t%d\n", resp.GetTotalRemoved())
+	fmt.Fprintf(tw, "consecutive_failures\t%d\n", resp.GetConsecutiveFailures())
+	fmt.Fprintf(tw, "last_error\t%s\n", resp.GetLastError())
+	return tw.Flush()
+}
+
+func writeInvokerEndpoints(w io.Writer, resp *sqsd.InvokerEndpointsResponse) error {
+	if !resp.GetReported() {
+		_, err := fmt.Fprintln(w, "invoker endpoints are not reported")
+		return err
+	}
+	fmt.Fprintf(w, "strategy: %s\n", resp.GetStrategy())
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tWEIGHT\tHEALTHY\tEJECTED_UNTIL\tIN_FLIGHT\tREQUESTS\tFAILURES\tLAST_ERROR")
+	for _, e := range resp.GetEndpoints() {
+		ejectedUntil := "-"
+		if e.GetEjected() {
+			ejectedUntil = e.GetEjectedUntil().AsTime().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%t\t%s\t%d\t%d\t%d\t%s\n",
+			e.GetUrl(), e.GetWeight(), e.GetHealthy(), ejectedUntil,
+			e.GetInFlight(), e.GetTotalRequests(), e.GetTotalFailures(), e.GetLastError())
+	}
+	return tw.Flush()
+}
+
+func writeWorkerStatus(w io.Writer, resp *sqsd.WorkerStatusResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "panics\t%d\n", resp.GetPanics())
+	if t := resp.GetLastPanicAt(); t != nil {
+		fmt.Fprintf(tw, "last_panic_at\t%s\n", t.AsTime().Format(time.RFC3339Nano))
+		fmt.Fprintf(tw, "last_panic_message_id\t%s\n", resp.GetLastPanicMessageId())
+		fmt.Fprintf(tw, "last_panic\t%s\n", resp.GetLastPanic())
+	}
+	fmt.Fprintf(tw, "timeouts\t%d\n", resp.GetTimeouts())
+	return tw.Flush()
+}
+
+func writeCanary(w io.Writer, resp *sqsd.CanaryResponse) error {
+	if !resp.GetReported() {
+		_, err := fmt.Fprintln(w, "canary is not configured")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "weight\t%d\n", resp.GetWeight())
+	fmt.Fprintln(tw, "TARGET\tREQUESTS\tFAILURES")
+	fmt.Fprintf(tw, "stable\t%d\t%d\n", resp.GetStableRequests(), resp.GetStableFailures())
+	fmt.Fprintf(tw, "canary\t%d\t%d\n", resp.GetCanaryRequests(), resp.GetCanaryFailures())
+	return tw.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	sqsd "github.com/taiyoh/sqsd"
+)
+
+var testLockedAt = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+type adminTestServer struct {
+	sqsd.UnimplementedMonitoringServiceServer
+	released     []string
+	canaryWeight int64
+}
+
+func (s *adminTestServer) ListLocks(_ context.Context, req *sqsd.ListLocksRequest) (*sqsd.ListLocksResponse, error) {
+	return &sqsd.ListLocksResponse{
+		Locks: []*sqsd.LockEntry{{Key: "id:1", LockedAt: timestamppb.New(testLockedAt)}},
+		Total: 10,
+	}, nil
+}
+
+func (s *adminTestServer) GetLock(_ context.Context, req *sqsd.GetLockRequest) (*sqsd.GetLockResponse, error) {
+	return nil, status.Error(codes.NotFound, "lock not found")
+}
+
+func (s *adminTestServer) ReleaseLock(_ context.Context, req *sqsd.ReleaseLockRequest) (*sqsd.ReleaseLockResponse, error) {
+	s.released = append(s.released, req.GetKey())
+	return &sqsd.ReleaseLockResponse{}, nil
+}
+
+func (s *adminTestServer) InvokerEndpoints(_ context.Context, req *sqsd.InvokerEndpointsRequest) (*sqsd.InvokerEndpointsResponse, error) {
+	return &sqsd.InvokerEndpointsResponse{
+		Reported: true,
+		Strategy: "round_robin",
+		Endpoints: []*sqsd.InvokerEndpoint{
+			{Url: "http://a/jobs", Weight: 1, Healthy: true, TotalRequests: 10},
+			{Url: "http://b/jobs", Weight: 1, Healthy: true, Ejected: true, EjectedUntil: timestamppb.New(testLockedAt), TotalRequests: 5, TotalFailures: 5, LastError: "failure response: 500"},
+		},
+	}, nil
+}
+
+func (s *adminTestServer) WorkerStatus(_ context.Context, req *sqsd.WorkerStatusRequest) (*sqsd.WorkerStatusResponse, error) {
+	return &sqsd.WorkerStatusResponse{
+		Panics:             2,
+		LastPanicAt:        timestamppb.New(testLockedAt),
+		LastPanicMessageId: "id:1",
+		LastPanic:          "boom",
+		Timeouts:           3,
+	}, nil
+}
+
+func (s *adminTestServer) Canary(_ context.Context, req *sqsd.CanaryRequest) (*sqsd.CanaryResponse, error) {
+	return &sqsd.CanaryResponse{Reported: true, Weight: s.canaryWeight, StableRequests: 90, StableFailures: 1, CanaryRequests: 10, CanaryFailures: 2}, nil
+}
+
+func (s *adminTestServer) SetCanaryWeight(ctx context.Context, req *sqsd.SetCanaryWeightRequest) (*sqsd.CanaryResponse, error) {
+	if req.GetWeight() > 100 {
+		return nil, status.Error(codes.InvalidArgument, "canary weight must be between 0 and 100")
+	}
+	s.canaryWeight = req.GetWeight()
+	return s.Canary(ctx, &sqsd.CanaryRequest{})
+}
+
+func TestRunAdmin(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &adminTestServer{}
+	server := grpc.NewServer()
+	sqsd.RegisterMonitoringServiceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	ctx := context.Background()
+	addr := lis.Addr().String()
+
+	t.Run("locks list", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "locks", "list", "-limit", "1"}, &buf))
+		assert.Equal(t, "KEY   LOCKED_AT\nid:1  2023-10-01T12:00:00Z\ntotal: 10\n", buf.String())
+	})
+
+	t.Run("locks get", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := runAdmin(ctx, []string{"-addr", addr, "locks", "get", "id:2"}, &buf)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("locks release", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "locks", "release", "id:1"}, &buf))
+		assert.Equal(t, "released: id:1\n", buf.String())
+		assert.Equal(t, []string{"id:1"}, srv.released)
+	})
+
+	t.Run("locker health is not implemented", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := runAdmin(ctx, []string{"-addr", addr, "locker", "health"}, &buf)
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("invoker endpoints", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "invoker", "endpoints"}, &buf))
+		assert.Equal(t, `strategy: round_robin
+URL            WEIGHT  HEALTHY  EJECTED_UNTIL         IN_FLIGHT  REQUESTS  FAILURES  LAST_ERROR
+http://a/jobs  1       true     -                     0          10        0         
+http://b/jobs  1       true     2023-10-01T12:00:00Z  0          5         5         failure response: 500
+`, buf.String())
+	})
+
+	t.Run("worker status", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "worker", "status"}, &buf))
+		assert.Equal(t, `panics                 2
+last_panic_at          2023-10-01T12:00:00Z
+last_panic_message_id  id:1
+last_panic             boom
+timeouts               3
+`, buf.String())
+	})
+
+	t.Run("canary", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "canary", "set", "25"}, &buf))
+		assert.Equal(t, int64(25), srv.canaryWeight)
+		buf.Reset()
+		assert.NoError(t, runAdmin(ctx, []string{"-addr", addr, "canary", "status"}, &buf))
+		assert.Equal(t, `weight  25
+TARGET  REQUESTS  FAILURES
+stable  90        1
+canary  10        2
+`, buf.String())
+		err := runAdmin(ctx, []string{"-addr", addr, "canary", "set", "101"}, &buf)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.ErrorIs(t, runAdmin(ctx, []string{"-addr", addr, "canary", "set", "x"}, &buf), errAdminUsage)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.ErrorIs(t, runAdmin(ctx, []string{"-addr", addr, "locks"}, &buf), errAdminUsage)
+		assert.ErrorIs(t, runAdmin(ctx, []string{"-addr", addr, "locks", "release"}, &buf), errAdminUsage)
+		assert.Contains(t, buf.String(), "usage: sqsd admin")
+	})
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/joho/godotenv"
 	"github.com/redis/rueidis"
@@ -25,6 +31,7 @@ import (
 	"github.com/taiyoh/sqsd/locker"
 	memorylocker "github.com/taiyoh/sqsd/locker/memory"
 	redislocker "github.com/taiyoh/sqsd/locker/redis"
+	tieredlocker "github.com/taiyoh/sqsd/locker/tiered"
 )
 
 type awsConf struct {
@@ -46,53 +53,285 @@ func (c *awsConf) UnmarshalText(b []byte) error {
 
 type config struct {
 	RawURL          string
+	Command         string
+	RetainExitCodes []int
+	CommandPool     bool
+	PoolMaxJobs     int
+	FastCGIAddr     string
+	FastCGIScript   string
+	GRPCAddr        string
+	LambdaAddr      string
+	Lambda          lambdaInvoker
+	GRPCPoolSize    int
+	DeadLetterQueue string
+	ReplyQueue      string
+	ReplyAttribute  string
+	RoutesFile      string
+	Routes          *routes
+	Shadow          shadow
+	Canary          canary
+	HTTP            httpInvoker
+	Balancer        balancer
 	QueueURL        string
 	Duration        time.Duration
 	UnlockInterval  time.Duration
+	UnlockJitter    time.Duration
+	UnlockBackoff   time.Duration
 	LockExpire      time.Duration
 	FetcherWaitTime time.Duration
 	FetcherParallel int
+	Visibility      time.Duration
+	Deadline        deadline
+	Decode          decode
 	InvokerParallel int
+	BatchSize       int
+	BatchWindow     time.Duration
 	MonitoringPort  int
 	LogLevel        slog.Level
 	RedisLocker     *redisLocker
+	LockFailure     lockFailure
+	DedupKey        string
 	Region          awsConf
 	Profile         string
 	Endpoint        awsConf
 }
 
+// deadline configures per-message timeout which shortens INVOKER_TIMEOUT.
+type deadline struct {
+	Attribute    string
+	ByVisibility bool
+	Margin       time.Duration
+}
+
+// middlewares returns middlewares which apply per-message timeout.
+func (d deadline) middlewares() []sqsd.Middleware {
+	var mws []sqsd.Middleware
+	if d.ByVisibility {
+		mws = append(mws, sqsd.VisibilityDeadline(d.Margin))
+	}
+	if d.Attribute != "" {
+		mws = append(mws, sqsd.TimeoutAttribute(d.Attribute))
+	}
+	return mws
+}
+
+// decode configures payload decoding before invocation.
+type decode struct {
+	Enabled  bool
+	Encoding string
+}
+
+// middlewares returns middleware which decodes payload if it is enabled by PAYLOAD_DECODE or PAYLOAD_ENCODING.
+func (d decode) middlewares() ([]sqsd.Middleware, error) {
+	if !d.Enabled && d.Encoding == "" {
+		return nil, nil
+	}
+	mw, err := sqsd.DecodePayload(d.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return []sqsd.Middleware{mw}, nil
+}
+
 type redisLocker struct {
-	Host    string
-	DBName  int
-	KeyName string
+	Host       string
+	DBName     int
+	KeyName    string
+	LocalCache bool
+}
+
+type httpInvoker struct {
+	MaxIdleConns    int
+	IdleConnTimeout time.Duration
+	H2C             bool
+	Headers         []string
+	BasicAuth       string
+	BearerToken     string
+	TLSCert         string
+	TLSKey          string
+	TLSCA           string
+	TLSServerName   string
+	SigningSecret   string
+	CloudEvents     string
+	// CloudEventsSource is queue ARN, which is resolved by Load.
+	CloudEventsSource string
+}
+
+type balancer struct {
+	Weights             []int
+	Strategy            sqsd.BalanceStrategy
+	HealthCheckPath     string
+	HealthCheckInterval time.Duration
+	EjectionThreshold   int
+	EjectionDuration    time.Duration
+}
+
+type lambdaInvoker struct {
+	Function    string
+	Qualifier   string
+	EndpointURL string
+}
+
+type shadow struct {
+	URL  string
+	Rate float64
+}
+
+type canary struct {
+	URL    string
+	Weight int
+}
+
+type lockFailure struct {
+	Policy           sqsd.LockFailurePolicy
+	Pause            time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 func (c *config) Load() error {
 	c.Region.target = "region"
 	c.Endpoint.target = "endpoint"
 	if err := typedenv.Scan(
-		typedenv.RequiredDirect("INVOKER_URL", &c.RawURL),
+		typedenv.LookupDirect("INVOKER_URL", &c.RawURL),
+		typedenv.LookupDirect("INVOKER_COMMAND", &c.Command),
+		typedenv.Default("INVOKER_RETAIN_EXIT_CODES", typedenv.Slice(&c.RetainExitCodes), "75"),
+		typedenv.DefaultDirect("INVOKER_COMMAND_POOL", &c.CommandPool, "false"),
+		typedenv.DefaultDirect("INVOKER_POOL_MAX_JOBS", &c.PoolMaxJobs, "0"),
+		typedenv.LookupDirect("INVOKER_FASTCGI_ADDR", &c.FastCGIAddr),
+		typedenv.LookupDirect("INVOKER_FASTCGI_SCRIPT", &c.FastCGIScript),
+		typedenv.LookupDirect("INVOKER_GRPC_ADDR", &c.GRPCAddr),
+		typedenv.LookupDirect("INVOKER_LAMBDA_RUNTIME_ADDR", &c.LambdaAddr),
+		typedenv.LookupDirect("INVOKER_LAMBDA_FUNCTION", &c.Lambda.Function),
+		typedenv.LookupDirect("INVOKER_LAMBDA_QUALIFIER", &c.Lambda.Qualifier),
+		typedenv.LookupDirect("INVOKER_LAMBDA_ENDPOINT_URL", &c.Lambda.EndpointURL),
+		typedenv.DefaultDirect("INVOKER_GRPC_POOL_SIZE", &c.GRPCPoolSize, "1"),
+		typedenv.LookupDirect("DEAD_LETTER_QUEUE_URL", &c.DeadLetterQueue),
+		typedenv.LookupDirect("RESPONSE_QUEUE_URL", &c.ReplyQueue),
+		typedenv.DefaultDirect("RESPONSE_QUEUE_ATTRIBUTE", &c.ReplyAttribute, "ResponseQueueUrl"),
+		typedenv.LookupDirect("ROUTES_FILE", &c.RoutesFile),
+		typedenv.LookupDirect("INVOKER_SHADOW_URL", &c.Shadow.URL),
+		typedenv.DefaultDirect("INVOKER_SHADOW_RATE", &c.Shadow.Rate, "1"),
+		typedenv.LookupDirect("INVOKER_CANARY_URL", &c.Canary.URL),
+		typedenv.DefaultDirect("INVOKER_CANARY_WEIGHT", &c.Canary.Weight, "0"),
+		typedenv.DefaultDirect("INVOKER_MAX_IDLE_CONNS", &c.HTTP.MaxIdleConns, "0"),
+		typedenv.DefaultDirect("INVOKER_IDLE_CONN_TIMEOUT", &c.HTTP.IdleConnTimeout, "90s"),
+		typedenv.DefaultDirect("INVOKER_H2C", &c.HTTP.H2C, "false"),
+		typedenv.Lookup("INVOKER_HEADERS", typedenv.Slice(&c.HTTP.Headers)),
+		typedenv.LookupDirect("INVOKER_BASIC_AUTH", &c.HTTP.BasicAuth),
+		typedenv.LookupDirect("INVOKER_BEARER_TOKEN", &c.HTTP.BearerToken),
+		typedenv.LookupDirect("INVOKER_TLS_CERT", &c.HTTP.TLSCert),
+		typedenv.LookupDirect("INVOKER_TLS_KEY", &c.HTTP.TLSKey),
+		typedenv.LookupDirect("INVOKER_TLS_CA", &c.HTTP.TLSCA),
+		typedenv.LookupDirect("INVOKER_TLS_SERVER_NAME", &c.HTTP.TLSServerName),
+		typedenv.LookupDirect("INVOKER_SIGNING_SECRET", &c.HTTP.SigningSecret),
+		typedenv.LookupDirect("INVOKER_CLOUDEVENTS", &c.HTTP.CloudEvents),
+		typedenv.Lookup("INVOKER_WEIGHTS", typedenv.Slice(&c.Balancer.Weights)),
+		typedenv.Default("INVOKER_BALANCE_STRATEGY", &c.Balancer.Strategy, "round_robin"),
+		typedenv.LookupDirect("INVOKER_HEALTH_CHECK_PATH", &c.Balancer.HealthCheckPath),
+		typedenv.DefaultDirect("INVOKER_HEALTH_CHECK_INTERVAL", &c.Balancer.HealthCheckInterval, "10s"),
+		typedenv.DefaultDirect("INVOKER_EJECTION_THRESHOLD", &c.Balancer.EjectionThreshold, "5"),
+		typedenv.DefaultDirect("INVOKER_EJECTION_DURATION", &c.Balancer.EjectionDuration, "30s"),
 		typedenv.RequiredDirect("QUEUE_URL", &c.QueueURL),
 		typedenv.RequiredDirect("SSO_PROFILE", &c.Profile),
 		typedenv.DefaultDirect("INVOKER_TIMEOUT", &c.Duration, "60s"),
 		typedenv.DefaultDirect("UNLOCK_INTERVAL", &c.UnlockInterval, "1m"),
+		typedenv.LookupDirect("UNLOCK_JITTER", &c.UnlockJitter),
+		typedenv.DefaultDirect("UNLOCK_RETRY_BACKOFF", &c.UnlockBackoff, "1s"),
 		typedenv.DefaultDirect("LOCK_EXPIRE", &c.LockExpire, "24h"),
 		typedenv.DefaultDirect("FETCHER_WAIT_TIME", &c.FetcherWaitTime, "1s"),
 		typedenv.DefaultDirect("FETCHER_PARALLEL_COUNT", &c.FetcherParallel, "1"),
+		typedenv.DefaultDirect("FETCHER_VISIBILITY_TIMEOUT", &c.Visibility, "30s"),
+		typedenv.LookupDirect("INVOKER_TIMEOUT_ATTRIBUTE", &c.Deadline.Attribute),
+		typedenv.DefaultDirect("INVOKER_TIMEOUT_BY_VISIBILITY", &c.Deadline.ByVisibility, "false"),
+		typedenv.DefaultDirect("INVOKER_VISIBILITY_MARGIN", &c.Deadline.Margin, "1s"),
+		typedenv.DefaultDirect("PAYLOAD_DECODE", &c.Decode.Enabled, "false"),
+		typedenv.LookupDirect("PAYLOAD_ENCODING", &c.Decode.Encoding),
 		typedenv.DefaultDirect("INVOKER_PARALLEL_COUNT", &c.InvokerParallel, "1"),
+		typedenv.DefaultDirect("INVOKER_BATCH_SIZE", &c.BatchSize, "1"),
+		typedenv.DefaultDirect("INVOKER_BATCH_WINDOW", &c.BatchWindow, "100ms"),
 		typedenv.DefaultDirect("MONITORING_PORT", &c.MonitoringPort, "6969"),
 		typedenv.Default("LOG_LEVEL", &c.LogLevel, "info"),
 		typedenv.Default("AWS_REGION", &c.Region, "ap-northeast-1"),
 		typedenv.Lookup("SQS_ENDPOINT_URL", &c.Endpoint),
+		typedenv.Default("LOCK_FAILURE_POLICY", &c.LockFailure.Policy, "skip"),
+		typedenv.DefaultDirect("LOCK_FAILURE_PAUSE", &c.LockFailure.Pause, "5s"),
+		typedenv.DefaultDirect("LOCKER_BREAKER_THRESHOLD", &c.LockFailure.BreakerThreshold, "0"),
+		typedenv.DefaultDirect("LOCKER_BREAKER_COOLDOWN", &c.LockFailure.BreakerCooldown, "30s"),
+		typedenv.DefaultDirect("DEDUP_KEY", &c.DedupKey, "message_id"),
 	); err != nil {
 		return err
 	}
+	if _, err := sqsd.ParseDedupKey(c.DedupKey); err != nil {
+		return err
+	}
+	// jitter follows UNLOCK_INTERVAL as well as default of locker.Jitter unless it is supplied.
+	if _, ok := os.LookupEnv("UNLOCK_JITTER"); !ok {
+		c.UnlockJitter = c.UnlockInterval / 10
+	}
+	if c.RoutesFile != "" {
+		rs, err := loadRoutes(c.RoutesFile)
+		if err != nil {
+			return err
+		}
+		c.Routes = rs
+	}
+	invokers := c.targetInvokers()
+	switch {
+	case invokers == 0 && c.Routes == nil:
+		return errors.New("INVOKER_URL, INVOKER_COMMAND, INVOKER_FASTCGI_ADDR, INVOKER_GRPC_ADDR, INVOKER_LAMBDA_RUNTIME_ADDR, INVOKER_LAMBDA_FUNCTION or ROUTES_FILE is required")
+	case invokers > 1:
+		return errors.New("INVOKER_URL, INVOKER_COMMAND, INVOKER_FASTCGI_ADDR, INVOKER_GRPC_ADDR, INVOKER_LAMBDA_RUNTIME_ADDR and INVOKER_LAMBDA_FUNCTION are exclusive")
+	case c.FastCGIAddr != "" && c.FastCGIScript == "":
+		return errors.New("INVOKER_FASTCGI_SCRIPT is required for INVOKER_FASTCGI_ADDR")
+	case c.BatchSize > 1 && (c.RawURL == "" && c.Lambda.Function == "" || c.Routes != nil || c.Shadow.URL != "" || c.Canary.URL != ""):
+		return errors.New("INVOKER_BATCH_SIZE is supported only by INVOKER_URL or INVOKER_LAMBDA_FUNCTION without ROUTES_FILE, INVOKER_SHADOW_URL and INVOKER_CANARY_URL")
+	case c.Visibility < time.Second || c.Visibility > 12*time.Hour:
+		return errors.New("FETCHER_VISIBILITY_TIMEOUT must be between 1s and 12h")
+	case c.Deadline.ByVisibility && c.Deadline.Margin >= c.Visibility:
+		return errors.New("INVOKER_VISIBILITY_MARGIN must be shorter than FETCHER_VISIBILITY_TIMEOUT")
+	case c.BatchSize > 1 && (c.Decode.Enabled || c.Decode.Encoding != ""):
+		return errors.New("PAYLOAD_DECODE and PAYLOAD_ENCODING are not supported by INVOKER_BATCH_SIZE")
+	case c.Shadow.Rate < 0 || c.Shadow.Rate > 1:
+		return errors.New("INVOKER_SHADOW_RATE must be between 0 and 1")
+	case c.Canary.Weight < 0 || c.Canary.Weight > 100:
+		return errors.New("INVOKER_CANARY_WEIGHT must be between 0 and 100")
+	case (c.HTTP.TLSCert == "") != (c.HTTP.TLSKey == ""):
+		return errors.New("INVOKER_TLS_CERT and INVOKER_TLS_KEY must be set together")
+	case c.HTTP.BasicAuth != "" && c.HTTP.BearerToken != "":
+		return errors.New("INVOKER_BASIC_AUTH and INVOKER_BEARER_TOKEN are exclusive")
+	case c.HTTP.BasicAuth != "" && !strings.Contains(c.HTTP.BasicAuth, ":"):
+		return errors.New("INVOKER_BASIC_AUTH must be user:password")
+	}
+	if _, err := sqsd.ParseContentEncoding(c.Decode.Encoding); err != nil {
+		return fmt.Errorf("invalid PAYLOAD_ENCODING: %w", err)
+	}
+	if c.HTTP.CloudEvents != "" {
+		var mode sqsd.CloudEventsMode
+		if err := mode.UnmarshalText([]byte(c.HTTP.CloudEvents)); err != nil {
+			return fmt.Errorf("invalid INVOKER_CLOUDEVENTS: %w", err)
+		}
+		source, err := sqsd.QueueARN(c.QueueURL, aws.StringValue(c.Region.Config.Region))
+		if err != nil {
+			return fmt.Errorf("INVOKER_CLOUDEVENTS requires queue ARN: %w", err)
+		}
+		c.HTTP.CloudEventsSource = source
+	}
+	if w := c.Balancer.Weights; len(w) > 0 && len(w) != len(c.invokerURLs()) {
+		return errors.New("INVOKER_WEIGHTS must have the same count as INVOKER_URL")
+	}
+	for _, h := range c.HTTP.Headers {
+		if k, _, ok := strings.Cut(h, ":"); !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid INVOKER_HEADERS: %s", h)
+		}
+	}
 
 	var rl redisLocker
 	if err := typedenv.Scan(
 		typedenv.RequiredDirect("REDIS_LOCKER_HOST", &rl.Host),
 		typedenv.DefaultDirect("REDIS_LOCKER_DBNAME", &rl.DBName, "0"),
 		typedenv.RequiredDirect("REDIS_LOCKER_KEYNAME", &rl.KeyName),
+		typedenv.DefaultDirect("REDIS_LOCKER_LOCAL_CACHE", &rl.LocalCache, "false"),
 	); err == nil {
 		c.RedisLocker = &rl
 	}
@@ -100,7 +339,22 @@ func (c *config) Load() error {
 	return nil
 }
 
+// invokerURLs returns URLs in INVOKER_URL separated by comma.
+func (c *config) invokerURLs() []string {
+	if c.RawURL == "" {
+		return nil
+	}
+	return strings.Split(c.RawURL, ",")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := runAdmin(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	loadEnvFromFile()
 
 	var args config
@@ -152,51 +406,272 @@ func main() {
 			log.Fatal(err)
 		}
 		queueLocker = redislocker.New(db, rl.KeyName)
-		logger.Info("redis queue locker is selected")
+		if rl.LocalCache {
+			queueLocker = tieredlocker.New(memorylocker.New(), queueLocker)
+			logger.Info("redis queue locker with local cache is selected")
+		} else {
+			logger.Info("redis queue locker is selected")
+		}
 	} else {
 		queueLocker = memorylocker.New()
 		logger.Info("memory queue locker is selected")
 	}
 
-	unlocker, err := locker.NewUnlocker(queueLocker, args.UnlockInterval, locker.ExpireDuration(args.LockExpire))
+	if lf := args.LockFailure; lf.BreakerThreshold > 0 {
+		queueLocker, err = locker.NewCircuitBreaker(queueLocker,
+			locker.FailureThreshold(lf.BreakerThreshold),
+			locker.Cooldown(lf.BreakerCooldown))
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Info("queue locker circuit breaker is enabled", "threshold", lf.BreakerThreshold, "cooldown", lf.BreakerCooldown.String())
+	}
+
+	unlocker, err := locker.NewUnlocker(queueLocker, args.UnlockInterval,
+		locker.ExpireDuration(args.LockExpire),
+		locker.Jitter(args.UnlockJitter),
+		locker.RetryBackoff(args.UnlockBackoff),
+		locker.UnlockerLogger(sqsd.NewLogger(slogHandlerOpts, os.Stderr, "sqsd-unlocker")))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ivk, err := sqsd.NewHTTPInvoker(args.RawURL, args.Duration)
+	ivk, err := newInvoker(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decodeMws, err := args.Decode.middlewares()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var maxMessages int64 = 1
+	dedupKey, err := sqsd.ParseDedupKey(args.DedupKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// receives messages as many as batch at once, which is up to 10 by SQS.
+	maxMessages := int64(min(max(args.BatchSize, 1), 10))
 
 	sys := sqsd.NewSystem(
 		sqsd.GatewayBuilder(queue, args.QueueURL, args.FetcherParallel, args.Duration,
 			sqsd.FetcherMaxMessages(maxMessages),
 			sqsd.FetcherWaitTime(args.FetcherWaitTime),
-			sqsd.FetcherQueueLocker(queueLocker)),
-		sqsd.ConsumerBuilder(ivk, args.InvokerParallel),
+			sqsd.FetcherVisibilityTimeout(args.Visibility),
+			sqsd.FetcherQueueLocker(queueLocker),
+			sqsd.FetcherLockFailurePolicy(args.LockFailure.Policy, args.LockFailure.Pause),
+			sqsd.FetcherDedupKey(dedupKey),
+			sqsd.FetcherDeadLetterQueue(args.DeadLetterQueue),
+			sqsd.FetcherReplyQueue(args.ReplyQueue),
+			sqsd.FetcherReplyAttribute(args.ReplyAttribute)),
+		sqsd.ConsumerBuilder(ivk, args.InvokerParallel, append(args.Deadline.middlewares(), decodeMws...)...),
+		sqsd.BatchBuilder(args.BatchSize, args.BatchWindow),
 		sqsd.MonitorBuilder(args.MonitoringPort),
+		sqsd.UnlockerBuilder(unlocker),
 	)
 
 	logger.Info("start process")
-	logger.Info("queue settings", "url", args.QueueURL, "parallel", args.FetcherParallel, "wait_time", args.FetcherWaitTime.String(), "max_messages", maxMessages)
-	logger.Info("invoker settings", "url", args.RawURL, "parallel", args.InvokerParallel, "timeout", args.Duration.String())
+	logger.Info("queue settings", "url", args.QueueURL, "parallel", args.FetcherParallel, "wait_time", args.FetcherWaitTime.String(), "visibility_timeout", args.Visibility.String(), "max_messages", maxMessages, "dedup_key", args.DedupKey)
+	logger.Info("invoker settings", "url", args.RawURL, "command", args.Command, "fastcgi", args.FastCGIAddr, "grpc", args.GRPCAddr, "lambda_runtime", args.LambdaAddr, "lambda_function", args.Lambda.Function, "parallel", args.InvokerParallel, "timeout", args.Duration.String(), "timeout_attribute", args.Deadline.Attribute, "timeout_by_visibility", args.Deadline.ByVisibility, "payload_decode", args.Decode.Enabled, "payload_encoding", args.Decode.Encoding, "batch_size", args.BatchSize, "routes", args.RoutesFile, "shadow", args.Shadow.URL, "canary", args.Canary.URL)
 
 	ctx, cancel := signal.NotifyContext(
 		context.Background(),
 		syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 	defer cancel()
 
-	go unlocker.Run(ctx)
-
 	if err := sys.Run(ctx); err != nil {
 		log.Fatal(err)
 	}
 
+	if c, ok := ivk.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			logger.Error("failed to close invoker", "error", err)
+		}
+	}
+
 	logger.Info("end process")
 }
 
+// newInvoker returns invoker for INVOKER_COMMAND, INVOKER_FASTCGI_ADDR or INVOKER_GRPC_ADDR if it is supplied, otherwise HTTPInvoker.
+// INVOKER_COMMAND runs per message by ExecInvoker, or runs as worker processes by PoolInvoker.
+func newInvoker(args config) (sqsd.Invoker, error) {
+	ivk, err := newPrimaryInvoker(args)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := httpInvokerOptions(args.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	if args.Shadow.URL != "" {
+		shadow, err := sqsd.NewHTTPInvoker(args.Shadow.URL, args.Duration, opts...)
+		if err != nil {
+			return nil, err
+		}
+		ivk, err = sqsd.NewShadowInvoker(ivk, shadow,
+			sqsd.ShadowRate(args.Shadow.Rate),
+			sqsd.ShadowTimeout(args.Duration),
+			sqsd.ShadowConcurrency(args.InvokerParallel))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// canary wraps others, so that shadow mirrors messages only for stable.
+	if args.Canary.URL != "" {
+		canary, err := sqsd.NewHTTPInvoker(args.Canary.URL, args.Duration, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return sqsd.NewCanaryInvoker(ivk, canary, args.Canary.Weight)
+	}
+	return ivk, nil
+}
+
+func newPrimaryInvoker(args config) (sqsd.Invoker, error) {
+	if args.Routes == nil {
+		return newTargetInvoker(args)
+	}
+	var fallback sqsd.Invoker
+	if args.targetInvokers() > 0 {
+		ivk, err := newTargetInvoker(args)
+		if err != nil {
+			return nil, err
+		}
+		fallback = ivk
+	}
+	return newRoutingInvoker(args, fallback)
+}
+
+// targetInvokers returns count of invokers configured by environment variables such as INVOKER_URL.
+func (c *config) targetInvokers() int {
+	var n int
+	for _, v := range []string{c.RawURL, c.Command, c.FastCGIAddr, c.GRPCAddr, c.LambdaAddr, c.Lambda.Function} {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// newTargetInvoker returns invoker configured by environment variables such as INVOKER_URL.
+func newTargetInvoker(args config) (sqsd.Invoker, error) {
+	switch {
+	case args.LambdaAddr != "":
+		queueARN, err := sqsd.QueueARN(args.QueueURL, aws.StringValue(args.Region.Config.Region))
+		if err != nil {
+			return nil, err
+		}
+		return sqsd.NewLambdaRuntimeInvoker(args.LambdaAddr, args.Duration,
+			sqsd.LambdaEventSourceARN(queueARN))
+	case args.Lambda.Function != "":
+		queueARN, err := sqsd.QueueARN(args.QueueURL, aws.StringValue(args.Region.Config.Region))
+		if err != nil {
+			return nil, err
+		}
+		sess, err := session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+			Profile:           args.Profile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg := aws.NewConfig()
+		if args.Lambda.EndpointURL != "" {
+			cfg = cfg.WithEndpoint(args.Lambda.EndpointURL)
+		}
+		return sqsd.NewLambdaInvoker(lambda.New(sess, cfg), args.Lambda.Function, args.Duration,
+			sqsd.LambdaInvokeQualifier(args.Lambda.Qualifier),
+			sqsd.LambdaInvokeEventSourceARN(queueARN))
+	case args.GRPCAddr != "":
+		return sqsd.NewGRPCInvoker(args.GRPCAddr, args.Duration,
+			sqsd.GRPCPoolSize(args.GRPCPoolSize))
+	case args.FastCGIAddr != "":
+		return sqsd.NewFastCGIInvoker(args.FastCGIAddr, args.FastCGIScript, args.Duration)
+	case args.Command != "" && args.CommandPool:
+		return sqsd.NewPoolInvoker(strings.Fields(args.Command), args.InvokerParallel, args.Duration,
+			sqsd.PoolMaxJobs(args.PoolMaxJobs))
+	case args.Command != "":
+		return sqsd.NewExecInvoker(strings.Fields(args.Command), args.Duration,
+			sqsd.ExecRetainExitCodes(args.RetainExitCodes...))
+	}
+	opts, err := httpInvokerOptions(args.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	urls := args.invokerURLs()
+	b := args.Balancer
+	if len(urls) == 1 && b.HealthCheckPath == "" {
+		return sqsd.NewHTTPInvoker(args.RawURL, args.Duration, opts...)
+	}
+	endpoints := make([]sqsd.Endpoint, 0, len(urls))
+	for i, u := range urls {
+		ep := sqsd.Endpoint{URL: strings.TrimSpace(u)}
+		if len(b.Weights) > 0 {
+			ep.Weight = b.Weights[i]
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return sqsd.NewBalancedInvoker(endpoints, args.Duration,
+		sqsd.BalancerStrategy(b.Strategy),
+		sqsd.BalancerHealthCheck(b.HealthCheckPath, b.HealthCheckInterval),
+		sqsd.BalancerEjection(b.EjectionThreshold, b.EjectionDuration),
+		sqsd.BalancerHTTPOptions(opts...))
+}
+
+// httpInvokerOptions returns options of HTTPInvoker. TLS config is built only when any of INVOKER_TLS_* is supplied.
+func httpInvokerOptions(c httpInvoker) ([]sqsd.HTTPInvokerOption, error) {
+	opts := []sqsd.HTTPInvokerOption{
+		sqsd.HTTPMaxIdleConns(c.MaxIdleConns),
+		sqsd.HTTPIdleConnTimeout(c.IdleConnTimeout),
+	}
+	if c.H2C {
+		opts = append(opts, sqsd.HTTPH2C())
+	}
+	for _, h := range c.Headers {
+		k, v, _ := strings.Cut(h, ":")
+		opts = append(opts, sqsd.HTTPHeader(strings.TrimSpace(k), strings.TrimSpace(v)))
+	}
+	if user, pass, ok := strings.Cut(c.BasicAuth, ":"); ok {
+		opts = append(opts, sqsd.HTTPBasicAuth(user, pass))
+	}
+	if c.BearerToken != "" {
+		opts = append(opts, sqsd.HTTPBearerToken(c.BearerToken))
+	}
+	if c.SigningSecret != "" {
+		opts = append(opts, sqsd.HTTPSigningSecret([]byte(c.SigningSecret)))
+	}
+	if c.CloudEvents != "" {
+		var mode sqsd.CloudEventsMode
+		if err := mode.UnmarshalText([]byte(c.CloudEvents)); err != nil {
+			return nil, err
+		}
+		opts = append(opts, sqsd.HTTPCloudEvents(mode, c.CloudEventsSource))
+	}
+	if c.TLSCert == "" && c.TLSCA == "" && c.TLSServerName == "" {
+		return opts, nil
+	}
+	cfg := &tls.Config{ServerName: c.TLSServerName}
+	if c.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.TLSCA != "" {
+		b, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate is found in %s", c.TLSCA)
+		}
+		cfg.RootCAs = pool
+	}
+	return append(opts, sqsd.HTTPTLSConfig(cfg)), nil
+}
+
 var cwd, _ = os.Getwd()
 
 func loadEnvFromFile() {
@@ -1,23 +1,77 @@
 package main
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	sqsd "github.com/taiyoh/sqsd"
 )
 
 func TestConfigWithoutRedisLocker(t *testing.T) {
 	var conf config
 	t.Setenv("INVOKER_URL", "http://localhost:8080")
 	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
 
 	assert.NoError(t, conf.Load())
 	assert.Nil(t, conf.RedisLocker)
+	assert.Equal(t, lockFailure{
+		Policy:          sqsd.LockFailureSkip,
+		Pause:           5 * time.Second,
+		BreakerCooldown: 30 * time.Second,
+	}, conf.LockFailure)
+}
+
+func TestConfigUnlockJitter(t *testing.T) {
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 6*time.Second, conf.UnlockJitter)
+
+	t.Setenv("UNLOCK_INTERVAL", "10m")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, time.Minute, conf.UnlockJitter, "jitter follows interval")
+
+	t.Setenv("UNLOCK_JITTER", "0s")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, time.Duration(0), conf.UnlockJitter)
+}
+
+func TestConfigLockFailure(t *testing.T) {
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("LOCK_FAILURE_POLICY", "closed")
+	t.Setenv("LOCK_FAILURE_PAUSE", "10s")
+	t.Setenv("LOCKER_BREAKER_THRESHOLD", "3")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, lockFailure{
+		Policy:           sqsd.LockFailureClosed,
+		Pause:            10 * time.Second,
+		BreakerThreshold: 3,
+		BreakerCooldown:  30 * time.Second,
+	}, conf.LockFailure)
+
+	t.Setenv("LOCK_FAILURE_POLICY", "unknown")
+	assert.Error(t, conf.Load())
 }
 
 func TestConfigWithRedisLocker(t *testing.T) {
 	t.Setenv("INVOKER_URL", "http://localhost:8080")
 	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
 	t.Setenv("REDIS_LOCKER_HOST", "localhost:6739")
 
 	t.Run("redis locker variables are not enough", func(t *testing.T) {
@@ -37,4 +91,404 @@ func TestConfigWithRedisLocker(t *testing.T) {
 			KeyName: "hogefuga",
 		}, *conf.RedisLocker)
 	})
+
+	t.Run("redis locker with local cache", func(t *testing.T) {
+		var conf config
+		t.Setenv("REDIS_LOCKER_DBNAME", "3")
+		t.Setenv("REDIS_LOCKER_KEYNAME", "hogefuga")
+		t.Setenv("REDIS_LOCKER_LOCAL_CACHE", "true")
+		assert.NoError(t, conf.Load())
+		assert.True(t, conf.RedisLocker.LocalCache)
+	})
+}
+
+func TestConfigDedupKey(t *testing.T) {
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, "message_id", conf.DedupKey)
+
+	t.Setenv("DEDUP_KEY", "json:$.order.id")
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, "json:$.order.id", conf.DedupKey)
+
+	t.Setenv("DEDUP_KEY", "unknown")
+	assert.EqualError(t, conf.Load(), "unknown dedup key: unknown")
+}
+
+func TestConfigInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+
+	var conf config
+	assert.EqualError(t, conf.Load(), "INVOKER_URL, INVOKER_COMMAND, INVOKER_FASTCGI_ADDR, INVOKER_GRPC_ADDR, INVOKER_LAMBDA_RUNTIME_ADDR, INVOKER_LAMBDA_FUNCTION or ROUTES_FILE is required")
+
+	t.Setenv("INVOKER_COMMAND", "php /app/worker.php")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, "php /app/worker.php", conf.Command)
+	assert.Equal(t, []int{75}, conf.RetainExitCodes)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.ExecInvoker{}, ivk)
+
+	t.Setenv("INVOKER_COMMAND_POOL", "true")
+	t.Setenv("INVOKER_POOL_MAX_JOBS", "100")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 100, conf.PoolMaxJobs)
+	ivk, err = newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.PoolInvoker{}, ivk)
+
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_URL, INVOKER_COMMAND, INVOKER_FASTCGI_ADDR, INVOKER_GRPC_ADDR, INVOKER_LAMBDA_RUNTIME_ADDR and INVOKER_LAMBDA_FUNCTION are exclusive")
+}
+
+func TestConfigFastCGIInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_FASTCGI_ADDR", "unix:///run/php-fpm.sock")
+
+	var conf config
+	assert.EqualError(t, conf.Load(), "INVOKER_FASTCGI_SCRIPT is required for INVOKER_FASTCGI_ADDR")
+
+	t.Setenv("INVOKER_FASTCGI_SCRIPT", "/app/worker.php")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.FastCGIInvoker{}, ivk)
+}
+
+func TestConfigGRPCInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_GRPC_ADDR", "localhost:50051")
+	t.Setenv("INVOKER_GRPC_POOL_SIZE", "4")
+	t.Setenv("DEAD_LETTER_QUEUE_URL", "http://localhost:8080/dlq")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 4, conf.GRPCPoolSize)
+	assert.Equal(t, "http://localhost:8080/dlq", conf.DeadLetterQueue)
+	assert.Equal(t, "", conf.ReplyQueue)
+	assert.Equal(t, "ResponseQueueUrl", conf.ReplyAttribute)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.GRPCInvoker{}, ivk)
+	assert.NoError(t, ivk.(*sqsd.GRPCInvoker).Close())
+}
+
+func TestConfigHTTPInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "unix:///run/worker.sock:/jobs")
+
+	for _, tt := range []struct {
+		env     map[string]string
+		wantErr string
+	}{
+		{env: map[string]string{"INVOKER_HEADERS": "X-Tenant"}, wantErr: "invalid INVOKER_HEADERS: X-Tenant"},
+		{env: map[string]string{"INVOKER_BASIC_AUTH": "user"}, wantErr: "INVOKER_BASIC_AUTH must be user:password"},
+		{env: map[string]string{"INVOKER_BASIC_AUTH": "user:pass", "INVOKER_BEARER_TOKEN": "token"}, wantErr: "INVOKER_BASIC_AUTH and INVOKER_BEARER_TOKEN are exclusive"},
+		{env: map[string]string{"INVOKER_TLS_CERT": "cert.pem"}, wantErr: "INVOKER_TLS_CERT and INVOKER_TLS_KEY must be set together"},
+	} {
+		t.Run(tt.wantErr, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var conf config
+			assert.EqualError(t, conf.Load(), tt.wantErr)
+		})
+	}
+
+	t.Setenv("INVOKER_HEADERS", "X-Tenant: acme,X-Env: dev")
+	t.Setenv("INVOKER_BEARER_TOKEN", "token")
+	t.Setenv("INVOKER_MAX_IDLE_CONNS", "16")
+	t.Setenv("INVOKER_SIGNING_SECRET", "secret")
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, "secret", conf.HTTP.SigningSecret)
+	assert.Equal(t, []string{"X-Tenant: acme", "X-Env: dev"}, conf.HTTP.Headers)
+	assert.Equal(t, 16, conf.HTTP.MaxIdleConns)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.HTTPInvoker{}, ivk)
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(ca, []byte("invalid"), 0o600))
+	t.Setenv("INVOKER_TLS_CA", ca)
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	_, err = newInvoker(conf)
+	assert.EqualError(t, err, "no certificate is found in "+ca)
+}
+
+func TestConfigBalancedInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "http://worker1/jobs,http://worker2/jobs")
+	t.Setenv("INVOKER_WEIGHTS", "3")
+
+	var conf config
+	assert.EqualError(t, conf.Load(), "INVOKER_WEIGHTS must have the same count as INVOKER_URL")
+
+	t.Setenv("INVOKER_WEIGHTS", "3,1")
+	t.Setenv("INVOKER_BALANCE_STRATEGY", "weighted")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, []int{3, 1}, conf.Balancer.Weights)
+	assert.Equal(t, sqsd.BalanceWeighted, conf.Balancer.Strategy)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.BalancedInvoker{}, ivk)
+	bi := ivk.(*sqsd.BalancedInvoker)
+	defer bi.Close()
+	eps := bi.Endpoints()
+	assert.Len(t, eps, 2)
+	assert.Equal(t, "http://worker1/jobs", eps[0].URL)
+	assert.Equal(t, 3, eps[0].Weight)
+
+	t.Setenv("INVOKER_BALANCE_STRATEGY", "random")
+	conf = config{}
+	assert.Error(t, conf.Load())
+}
+
+func TestConfigBatch(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_COMMAND", "php /app/worker.php")
+	t.Setenv("INVOKER_BATCH_SIZE", "10")
+
+	var conf config
+	assert.EqualError(t, conf.Load(), "INVOKER_BATCH_SIZE is supported only by INVOKER_URL or INVOKER_LAMBDA_FUNCTION without ROUTES_FILE, INVOKER_SHADOW_URL and INVOKER_CANARY_URL")
+
+	t.Setenv("INVOKER_COMMAND", "")
+	t.Setenv("INVOKER_URL", "http://localhost:8080/batch")
+	t.Setenv("INVOKER_BATCH_WINDOW", "1s")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 10, conf.BatchSize)
+	assert.Equal(t, time.Second, conf.BatchWindow)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.Implements(t, (*sqsd.BatchInvoker)(nil), ivk)
+}
+
+func TestConfigRoutes(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	dir := t.TempDir()
+	writeRoutes := func(body string) string {
+		path := filepath.Join(dir, "routes.json")
+		assert.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+		return path
+	}
+
+	t.Setenv("ROUTES_FILE", writeRoutes(`{
+  "routes": [
+    {"name": "email", "match": "$.type == \"email\"", "url": "http://localhost:8080/email", "concurrency": 5},
+    {"match": "attribute:Type == \"report\"", "command": "php report.php", "timeout": "10m"}
+  ],
+  "defaultConcurrency": 10
+}`))
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, &routes{
+		Routes: []route{
+			{Name: "email", Match: `$.type == "email"`, URL: "http://localhost:8080/email", Concurrency: 5},
+			{Name: "route1", Match: `attribute:Type == "report"`, Command: "php report.php", Timeout: "10m", timeout: 10 * time.Minute},
+		},
+		DefaultConcurrency: 10,
+	}, conf.Routes)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.RoutingInvoker{}, ivk)
+	assert.ErrorIs(t, ivk.Invoke(context.Background(), sqsd.Message{ID: "id:1", Payload: []byte(`{"type":"sms"}`)}), sqsd.ErrNoRoute)
+
+	t.Setenv("INVOKER_URL", "http://localhost:8080/default")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	t.Setenv("INVOKER_BATCH_SIZE", "10")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_BATCH_SIZE is supported only by INVOKER_URL or INVOKER_LAMBDA_FUNCTION without ROUTES_FILE, INVOKER_SHADOW_URL and INVOKER_CANARY_URL")
+	t.Setenv("INVOKER_BATCH_SIZE", "1")
+
+	for body, msg := range map[string]string{
+		`{"routes": []}`: "ROUTES_FILE has no route",
+		`{"routes": [{"match": "$.type = 1", "url": "http://localhost"}]}`:                                "invalid ROUTES_FILE: invalid route rule: $.type = 1",
+		`{"routes": [{"name": "x", "match": "$.type == 1"}]}`:                                             "invalid ROUTES_FILE: either url or command is required: x",
+		`{"routes": [{"name": "x", "match": "$.type == 1", "url": "http://localhost", "command": "a"}]}`:  "invalid ROUTES_FILE: either url or command is required: x",
+		`{"routes": [{"name": "x", "match": "$.type == 1", "url": "http://localhost", "timeout": "10"}]}`: "invalid ROUTES_FILE: invalid timeout: x",
+	} {
+		t.Setenv("ROUTES_FILE", writeRoutes(body))
+		conf = config{}
+		assert.EqualError(t, conf.Load(), msg)
+	}
+}
+
+func TestConfigDeadline(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 30*time.Second, conf.Visibility)
+	assert.Equal(t, deadline{Margin: time.Second}, conf.Deadline)
+	assert.Empty(t, conf.Deadline.middlewares())
+
+	t.Setenv("FETCHER_VISIBILITY_TIMEOUT", "15m")
+	t.Setenv("INVOKER_TIMEOUT_ATTRIBUTE", "Timeout")
+	t.Setenv("INVOKER_TIMEOUT_BY_VISIBILITY", "true")
+	t.Setenv("INVOKER_VISIBILITY_MARGIN", "5s")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, 15*time.Minute, conf.Visibility)
+	assert.Equal(t, deadline{Attribute: "Timeout", ByVisibility: true, Margin: 5 * time.Second}, conf.Deadline)
+	assert.Len(t, conf.Deadline.middlewares(), 2)
+
+	t.Setenv("INVOKER_VISIBILITY_MARGIN", "15m")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_VISIBILITY_MARGIN must be shorter than FETCHER_VISIBILITY_TIMEOUT")
+
+	t.Setenv("FETCHER_VISIBILITY_TIMEOUT", "13h")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "FETCHER_VISIBILITY_TIMEOUT must be between 1s and 12h")
+}
+
+func TestConfigDecode(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	mws, err := conf.Decode.middlewares()
+	assert.NoError(t, err)
+	assert.Empty(t, mws)
+
+	t.Setenv("PAYLOAD_DECODE", "true")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	mws, err = conf.Decode.middlewares()
+	assert.NoError(t, err)
+	assert.Len(t, mws, 1)
+
+	t.Setenv("PAYLOAD_DECODE", "false")
+	t.Setenv("PAYLOAD_ENCODING", "gzip,base64")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, decode{Encoding: "gzip,base64"}, conf.Decode)
+	mws, err = conf.Decode.middlewares()
+	assert.NoError(t, err)
+	assert.Len(t, mws, 1)
+
+	t.Setenv("INVOKER_BATCH_SIZE", "10")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "PAYLOAD_DECODE and PAYLOAD_ENCODING are not supported by INVOKER_BATCH_SIZE")
+	t.Setenv("INVOKER_BATCH_SIZE", "1")
+
+	t.Setenv("PAYLOAD_ENCODING", "br")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "invalid PAYLOAD_ENCODING: unknown content encoding: br")
+}
+
+func TestConfigCloudEvents(t *testing.T) {
+	t.Setenv("QUEUE_URL", "https://sqs.ap-northeast-1.amazonaws.com/123456789012/jobs")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	t.Setenv("INVOKER_CLOUDEVENTS", "structured")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, "structured", conf.HTTP.CloudEvents)
+	assert.Equal(t, "arn:aws:sqs:ap-northeast-1:123456789012:jobs", conf.HTTP.CloudEventsSource)
+	_, err := newInvoker(conf)
+	assert.NoError(t, err)
+
+	t.Setenv("INVOKER_CLOUDEVENTS", "batched")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "invalid INVOKER_CLOUDEVENTS: unknown cloudevents mode: batched")
+
+	t.Setenv("INVOKER_CLOUDEVENTS", "binary")
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_CLOUDEVENTS requires queue ARN: invalid queue url: http://localhost:8080")
+}
+
+func TestConfigShadowAndCanary(t *testing.T) {
+	t.Setenv("QUEUE_URL", "http://localhost:8080")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_URL", "http://localhost:8080/v1")
+	t.Setenv("INVOKER_SHADOW_URL", "http://localhost:8080/v2")
+	t.Setenv("INVOKER_SHADOW_RATE", "0.1")
+	t.Setenv("INVOKER_CANARY_URL", "http://localhost:8080/v3")
+	t.Setenv("INVOKER_CANARY_WEIGHT", "5")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, shadow{URL: "http://localhost:8080/v2", Rate: 0.1}, conf.Shadow)
+	assert.Equal(t, canary{URL: "http://localhost:8080/v3", Weight: 5}, conf.Canary)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.CanaryInvoker{}, ivk)
+	assert.Equal(t, 5, ivk.(sqsd.CanaryController).CanaryStats().Weight)
+	assert.NoError(t, ivk.(*sqsd.CanaryInvoker).Close())
+
+	t.Setenv("INVOKER_CANARY_URL", "")
+	conf = config{}
+	assert.NoError(t, conf.Load())
+	ivk, err = newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.ShadowInvoker{}, ivk)
+
+	t.Setenv("INVOKER_SHADOW_RATE", "2")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_SHADOW_RATE must be between 0 and 1")
+	t.Setenv("INVOKER_SHADOW_RATE", "1")
+	t.Setenv("INVOKER_CANARY_WEIGHT", "101")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_CANARY_WEIGHT must be between 0 and 100")
+}
+
+func TestConfigLambdaRuntimeInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "https://sqs.ap-northeast-1.amazonaws.com/123456789012/MyQueue")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("INVOKER_LAMBDA_RUNTIME_ADDR", "127.0.0.1:0")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.LambdaRuntimeInvoker{}, ivk)
+	assert.NotEmpty(t, ivk.(*sqsd.LambdaRuntimeInvoker).Addr())
+	assert.NoError(t, ivk.(*sqsd.LambdaRuntimeInvoker).Close())
+
+	t.Setenv("INVOKER_URL", "http://localhost:8080")
+	conf = config{}
+	assert.EqualError(t, conf.Load(), "INVOKER_URL, INVOKER_COMMAND, INVOKER_FASTCGI_ADDR, INVOKER_GRPC_ADDR, INVOKER_LAMBDA_RUNTIME_ADDR and INVOKER_LAMBDA_FUNCTION are exclusive")
+}
+
+func TestConfigLambdaInvoker(t *testing.T) {
+	t.Setenv("QUEUE_URL", "https://sqs.ap-northeast-1.amazonaws.com/123456789012/MyQueue")
+	t.Setenv("SSO_PROFILE", "default")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("INVOKER_LAMBDA_FUNCTION", "my-function")
+	t.Setenv("INVOKER_LAMBDA_QUALIFIER", "live")
+	t.Setenv("INVOKER_LAMBDA_ENDPOINT_URL", "http://localhost:3001")
+	t.Setenv("INVOKER_BATCH_SIZE", "10")
+
+	var conf config
+	assert.NoError(t, conf.Load())
+	assert.Equal(t, lambdaInvoker{Function: "my-function", Qualifier: "live", EndpointURL: "http://localhost:3001"}, conf.Lambda)
+	ivk, err := newInvoker(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &sqsd.LambdaInvoker{}, ivk)
+	assert.Implements(t, (*sqsd.BatchInvoker)(nil), ivk)
 }
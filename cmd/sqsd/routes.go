@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	sqsd "github.com/taiyoh/sqsd"
+)
+
+// routes is content of ROUTES_FILE.
+//
+//	{
+//	  "routes": [
+//	    {"name": "email", "match": "$.type == \"email\"", "url": "http://localhost:8080/email", "concurrency": 5},
+//	    {"name": "report", "match": "attribute:Type == \"report\"", "command": "php report.php", "timeout": "10m"}
+//	  ],
+//	  "defaultConcurrency": 10
+//	}
+//
+// Invoker configured by environment variables such as INVOKER_URL is used as default route.
+// timeout of route overrides INVOKER_TIMEOUT.
+type routes struct {
+	Routes             []route `json:"routes"`
+	DefaultConcurrency int     `json:"defaultConcurrency"`
+}
+
+type route struct {
+	Name        string `json:"name"`
+	Match       string `json:"match"`
+	URL         string `json:"url"`
+	Command     string `json:"command"`
+	Concurrency int    `json:"concurrency"`
+	Timeout     string `json:"timeout"`
+
+	timeout time.Duration
+}
+
+func loadRoutes(path string) (*routes, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rs routes
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, fmt.Errorf("invalid ROUTES_FILE: %w", err)
+	}
+	if len(rs.Routes) == 0 {
+		return nil, errors.New("ROUTES_FILE has no route")
+	}
+	for i, r := range rs.Routes {
+		if r.Name == "" {
+			rs.Routes[i].Name = fmt.Sprintf("route%d", i)
+		}
+		if _, err := sqsd.ParseRouteRule(r.Match); err != nil {
+			return nil, fmt.Errorf("invalid ROUTES_FILE: %w", err)
+		}
+		if (r.URL == "") == (r.Command == "") {
+			return nil, fmt.Errorf("invalid ROUTES_FILE: either url or command is required: %s", rs.Routes[i].Name)
+		}
+		if r.Timeout != "" {
+			d, err := time.ParseDuration(r.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid ROUTES_FILE: invalid timeout: %s", rs.Routes[i].Name)
+			}
+			rs.Routes[i].timeout = d
+		}
+	}
+	return &rs, nil
+}
+
+// newRoutingInvoker returns RoutingInvoker by args.Routes. fallback is used as default route if it is not nil.
+func newRoutingInvoker(args config, fallback sqsd.Invoker) (*sqsd.RoutingInvoker, error) {
+	opts, err := httpInvokerOptions(args.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]sqsd.Route, 0, len(args.Routes.Routes))
+	for _, r := range args.Routes.Routes {
+		match, err := sqsd.ParseRouteRule(r.Match)
+		if err != nil {
+			return nil, err
+		}
+		dur := args.Duration
+		if r.timeout > 0 {
+			dur = r.timeout
+		}
+		var ivk sqsd.Invoker
+		if r.URL != "" {
+			ivk, err = sqsd.NewHTTPInvoker(r.URL, dur, opts...)
+		} else {
+			ivk, err = sqsd.NewExecInvoker(strings.Fields(r.Command), dur,
+				sqsd.ExecRetainExitCodes(args.RetainExitCodes...))
+		}
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, sqsd.Route{Name: r.Name, Match: match, Invoker: ivk, Concurrency: r.Concurrency})
+	}
+	var ropts []sqsd.RoutingInvokerOption
+	if fallback != nil {
+		ropts = append(ropts, sqsd.RouterDefault(fallback, args.Routes.DefaultConcurrency))
+	}
+	return sqsd.NewRoutingInvoker(rs, ropts...)
+}
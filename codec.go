@@ -0,0 +1,168 @@
+package sqsd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncodingAttribute is message attribute which has encodings applied to payload in the order they were applied,
+// such as "gzip, base64" like Content-Encoding header of HTTP.
+const ContentEncodingAttribute = "content-encoding"
+
+// ContentTypeAttribute is message attribute which has media type of payload.
+// It is sent to worker as Content-Type, and payload is treated as JSON without it.
+const ContentTypeAttribute = "content-type"
+
+// maxDecodedPayloadSize limits size of decoded payload, so that small message can't be decompressed infinitely.
+var maxDecodedPayloadSize = 64 << 20
+
+// PayloadDecoder decodes payload which is encoded by a content encoding.
+type PayloadDecoder func([]byte) ([]byte, error)
+
+var payloadDecoders = map[string]PayloadDecoder{
+	"identity": func(b []byte) ([]byte, error) { return b, nil },
+	"base64":   decodeBase64,
+	"gzip":     decodeGzip,
+	"zstd":     decodeZstd,
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	out := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+	n, err := base64.StdEncoding.Decode(out, bytes.TrimSpace(b))
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func decodeGzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecoded(r)
+}
+
+func decodeZstd(b []byte) ([]byte, error) {
+	r, err := zstd.NewReader(bytes.NewReader(b), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecoded(r)
+}
+
+func readDecoded(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(maxDecodedPayloadSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxDecodedPayloadSize {
+		return nil, fmt.Errorf("decoded payload exceeds %d bytes", maxDecodedPayloadSize)
+	}
+	return b, nil
+}
+
+// ParseContentEncoding returns decoders for encodings such as "gzip, base64", in the order to decode.
+// Supported encodings are "base64", "gzip", "zstd" and "identity".
+func ParseContentEncoding(encoding string) ([]PayloadDecoder, error) {
+	var decoders []PayloadDecoder
+	for _, name := range strings.Split(encoding, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		dec, ok := payloadDecoders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown content encoding: %s", name)
+		}
+		// encodings are listed in the order they were applied, so that the last one is decoded first.
+		decoders = append([]PayloadDecoder{dec}, decoders...)
+	}
+	return decoders, nil
+}
+
+// DecodePayload returns Middleware which decodes payload before invocation,
+// by encodings in ContentEncodingAttribute of message, or by fixed encodings when message doesn't have it.
+// fixed is the same format as the attribute, and empty means that payload is not encoded.
+// Decoded message is invoked without ContentEncodingAttribute.
+// Payload which can't be decoded is treated as failure by DecodeError.
+func DecodePayload(fixed string) (Middleware, error) {
+	fixedDecoders, err := ParseContentEncoding(fixed)
+	if err != nil {
+		return nil, err
+	}
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			decoders := fixedDecoders
+			if encoding, ok := q.Attributes[ContentEncodingAttribute]; ok {
+				var err error
+				if decoders, err = ParseContentEncoding(encoding); err != nil {
+					return &DecodeError{Err: err}
+				}
+			}
+			if len(decoders) == 0 {
+				return next.Invoke(ctx, q)
+			}
+			payload := q.Payload
+			for _, dec := range decoders {
+				var err error
+				if payload, err = dec(payload); err != nil {
+					return &DecodeError{Err: err}
+				}
+			}
+			q.Payload = payload
+			q.Attributes = withoutAttribute(q.Attributes, ContentEncodingAttribute)
+			return next.Invoke(ctx, q)
+		})
+	}, nil
+}
+
+// withoutAttribute returns copy of attrs without name, so that attributes of original message are kept.
+func withoutAttribute(attrs map[string]string, name string) map[string]string {
+	if _, ok := attrs[name]; !ok {
+		return attrs
+	}
+	out := make(map[string]string, len(attrs)-1)
+	for k, v := range attrs {
+		if k != name {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// jsonPayload returns payload and attributes of message to be embedded in JSON as string.
+// Payload which is not valid UTF-8, such as decoded binary, is encoded by base64 and "base64" is appended to
+// ContentEncodingAttribute, because JSON string can't carry it as it is.
+func jsonPayload(q Message) (string, map[string]string) {
+	if utf8.Valid(q.Payload) {
+		return string(q.Payload), q.Attributes
+	}
+	attrs := make(map[string]string, len(q.Attributes)+1)
+	for k, v := range q.Attributes {
+		attrs[k] = v
+	}
+	attrs[ContentEncodingAttribute] = "base64"
+	if encoding := q.Attributes[ContentEncodingAttribute]; encoding != "" {
+		attrs[ContentEncodingAttribute] = encoding + ", base64"
+	}
+	return base64.StdEncoding.EncodeToString(q.Payload), attrs
+}
+
+// contentType returns media type of payload by ContentTypeAttribute, or "application/json" as default.
+func contentType(q Message) string {
+	if v := q.Attributes[ContentTypeAttribute]; v != "" {
+		return v
+	}
+	return "application/json"
+}
@@ -0,0 +1,152 @@
+package sqsd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, b []byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	assert.NoError(t, err)
+	defer enc.Close()
+	return enc.EncodeAll(b, nil)
+}
+
+func base64Bytes(b []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(b))
+}
+
+func TestParseContentEncoding(t *testing.T) {
+	decoders, err := ParseContentEncoding("gzip, Base64")
+	assert.NoError(t, err)
+	assert.Len(t, decoders, 2)
+
+	decoders, err = ParseContentEncoding("")
+	assert.NoError(t, err)
+	assert.Empty(t, decoders)
+
+	_, err = ParseContentEncoding("base64,br")
+	assert.EqualError(t, err, "unknown content encoding: br")
+}
+
+func TestDecodePayload(t *testing.T) {
+	payloads := make(chan Message, 1)
+	next := InvokerFunc(func(ctx context.Context, q Message) error {
+		payloads <- q
+		return nil
+	})
+	mw, err := DecodePayload("gzip, base64")
+	assert.NoError(t, err)
+	ivk := Chain(next, mw)
+
+	body := []byte{0x00, 0xff, 0xfe, '{', '}'}
+	attrs := map[string]string{ContentEncodingAttribute: "zstd, base64", "Type": "report"}
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: base64Bytes(zstdBytes(t, body)), Attributes: attrs}))
+	q := <-payloads
+	assert.Equal(t, body, q.Payload, "binary payload is kept as it is")
+	assert.Equal(t, map[string]string{"Type": "report"}, q.Attributes)
+	assert.Contains(t, attrs, ContentEncodingAttribute, "attributes of original message are not changed")
+
+	// fixed encodings are used without attribute.
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:2", Payload: base64Bytes(gzipBytes(t, []byte(`{"id":2}`)))}))
+	assert.Equal(t, []byte(`{"id":2}`), (<-payloads).Payload)
+
+	// attribute can disable fixed encodings.
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:3", Payload: []byte(`{}`), Attributes: map[string]string{ContentEncodingAttribute: "identity"}}))
+	assert.Equal(t, []byte(`{}`), (<-payloads).Payload)
+
+	var decodeErr *DecodeError
+	err = ivk.Invoke(context.Background(), Message{ID: "id:4", Payload: []byte(`not base64`)})
+	assert.ErrorAs(t, err, &decodeErr)
+	err = ivk.Invoke(context.Background(), Message{ID: "id:5", Payload: []byte(`{}`), Attributes: map[string]string{ContentEncodingAttribute: "br"}})
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.EqualError(t, err, "failed to decode payload: unknown content encoding: br")
+	assert.Empty(t, payloads)
+
+	_, err = DecodePayload("compress")
+	assert.EqualError(t, err, "unknown content encoding: compress")
+}
+
+func TestDecodePayloadLimit(t *testing.T) {
+	defer func(n int) { maxDecodedPayloadSize = n }(maxDecodedPayloadSize)
+	maxDecodedPayloadSize = 1024
+
+	b, err := decodeGzip(gzipBytes(t, make([]byte, 1024)))
+	assert.NoError(t, err)
+	assert.Len(t, b, 1024)
+	_, err = decodeZstd(zstdBytes(t, make([]byte, 1025)))
+	assert.EqualError(t, err, "decoded payload exceeds 1024 bytes")
+}
+
+func TestHTTPInvokerContentType(t *testing.T) {
+	typeCh := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		typeCh <- r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	ivk, err := NewHTTPInvoker(srv.URL, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	assert.Equal(t, "application/json", <-typeCh)
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{
+		ID:         "id:2",
+		Payload:    []byte{0x89, 'P', 'N', 'G'},
+		Attributes: map[string]string{ContentTypeAttribute: "image/png"},
+	}))
+	assert.Equal(t, "image/png", <-typeCh)
+}
+
+func TestJSONPayload(t *testing.T) {
+	body := []byte{0x1f, 0x8b, 0xff, 0x00, 'x'}
+	mw, err := DecodePayload("")
+	assert.NoError(t, err)
+	events := make(chan []byte, 1)
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		b, err := sqsEvent([]Message{q}, testQueueARN)
+		events <- b
+		return err
+	}), mw)
+
+	attrs := map[string]string{ContentEncodingAttribute: "gzip", "Type": "image"}
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: gzipBytes(t, body), Attributes: attrs}))
+	var event struct {
+		Records []sqsEventRecord `json:"Records"`
+	}
+	assert.NoError(t, json.Unmarshal(<-events, &event))
+	record := event.Records[0]
+	b, err := base64.StdEncoding.DecodeString(record.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, b, "binary payload is sent as base64 without corruption")
+	assert.Equal(t, "base64", record.MessageAttributes[ContentEncodingAttribute].StringValue)
+	assert.Equal(t, "image", record.MessageAttributes["Type"].StringValue)
+
+	// text payload is sent as it is.
+	payload, attrs := jsonPayload(Message{ID: "id:2", Payload: []byte(`{"id":2}`)})
+	assert.Equal(t, `{"id":2}`, payload)
+	assert.Nil(t, attrs)
+
+	// encodings which are not decoded are kept before base64.
+	payload, attrs = jsonPayload(Message{ID: "id:3", Payload: body, Attributes: map[string]string{ContentEncodingAttribute: "gzip"}})
+	assert.Equal(t, base64.StdEncoding.EncodeToString(body), payload)
+	assert.Equal(t, map[string]string{ContentEncodingAttribute: "gzip, base64"}, attrs)
+}
@@ -3,6 +3,7 @@ package sqsd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -16,15 +17,35 @@ import (
 // Message provides transition from sqs.Message
 type Message struct {
 	ID         string
-	Payload    string
+	Payload    []byte
 	Receipt    string
 	ReceivedAt time.Time
+	// SentAt is SentTimestamp of message. zero means unknown.
+	SentAt time.Time
+	// DedupKey is the key locked by Gateway, which is passed to worker as idempotency key.
+	DedupKey string
+	// Attributes has message attributes whose data type is String or Number.
+	Attributes map[string]string
+	// VisibleAt is the time when message becomes visible again unless it is removed. zero means unknown.
+	VisibleAt time.Time
 }
 
 type worker struct {
 	workings  sync.Map
 	invoker   Invoker
 	semaphore *semaphore.Weighted
+
+	mu       sync.Mutex
+	panics   panicStats
+	timeouts int64
+}
+
+// panicStats shows panics of invoker which are recovered by worker.
+type panicStats struct {
+	Count         int64
+	LastAt        time.Time
+	LastMessageID string
+	LastValue     string
 }
 
 func startWorker(ctx context.Context, ivk Invoker, broker chan Message, rm remover) *worker {
@@ -61,12 +82,30 @@ func (w *worker) CurrentWorkings(ctx context.Context) []*Task {
 
 type remover interface {
 	remove(ctx context.Context, msg Message) error
+	retryAfter(ctx context.Context, msg Message, delay time.Duration) error
+	unlock(ctx context.Context, msg Message)
+	deadLetter(ctx context.Context, msg Message) error
+	reply(ctx context.Context, msg Message, body []byte, invokeErr error) error
+	sendFollowUps(ctx context.Context, msg Message, msgs []FollowUpMessage) error
 }
 
 // ErrRetainMessage shows that this message should keep in queue.
 // So, this error means that worker must not to remove message.
 var ErrRetainMessage = errors.New("this message should be retained")
 
+// ErrDeadLetter shows that this message should be moved to dead-letter queue.
+var ErrDeadLetter = errors.New("this message should be moved to dead-letter queue")
+
+// RetryAfterError shows that this message should be visible again after Delay.
+type RetryAfterError struct {
+	Delay time.Duration
+}
+
+// Error implements error interface.
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("this message should be retried after %s", e.Delay)
+}
+
 func (w *worker) wrappedProcess(msg Message, rm remover) {
 	ctx := context.Background()
 
@@ -83,21 +122,101 @@ func (w *worker) wrappedProcess(msg Message, rm remover) {
 
 	logger := getLogger().With("message_id", msg.ID)
 	logger.Debug("start to invoke.")
-	switch err := w.invoker.Invoke(ctx, msg); err {
-	case nil:
+	ctx, rec := withResponseRecorder(ctx)
+	err := recoverPanic(func() error {
+		return w.invoker.Invoke(ctx, msg)
+	})
+	var retryErr *RetryAfterError
+	var panicErr *PanicError
+	switch {
+	case err == nil:
 		logger.Debug("succeeded to invoke.")
+		// message is kept in queue and its dedup key is released when follow-ups are not sent,
+		// so that invocation and follow-ups are retried together after visibility timeout.
+		// follow-ups are delivered at least once: ones sent before failure, or before failure of removing message, are sent again by retry.
+		if err := rm.sendFollowUps(ctx, msg, rec.FollowUps()); err != nil {
+			logger.Error("failed to send follow-up messages. message is kept in queue.", "error", err)
+			rm.unlock(ctx, msg)
+			return
+		}
+		w.reply(ctx, msg, rec, nil, rm)
 		if err := rm.remove(ctx, msg); err != nil {
 			logger.Warn("failed to remove message", "error", err)
 		}
-	case locker.ErrQueueExists:
+	case errors.Is(err, locker.ErrQueueExists):
 		logger.Warn("received message is duplicated")
-	case ErrRetainMessage:
+	case errors.Is(err, ErrRetainMessage):
 		logger.Info("received message should be retained")
+		rm.unlock(ctx, msg)
+	case errors.As(err, &retryErr):
+		logger.Info("received message should be retried", "delay", retryErr.Delay.String())
+		if err := rm.retryAfter(ctx, msg, retryErr.Delay); err != nil {
+			logger.Warn("failed to change visibility of message", "error", err)
+		}
+	case errors.Is(err, ErrDeadLetter):
+		logger.Info("received message should be moved to dead-letter queue")
+		w.reply(ctx, msg, rec, err, rm)
+		if err := rm.deadLetter(ctx, msg); err != nil {
+			logger.Error("failed to move message to dead-letter queue", "error", err)
+		}
+	case errors.As(err, &panicErr):
+		// message is kept in queue and its dedup key is released, so that it is retried after visibility timeout or moved by redrive policy.
+		w.recordPanic(msg, panicErr)
+		logger.Error("invoker panics. message is treated as failure.",
+			"panic", fmt.Sprint(panicErr.Value),
+			"stack", string(panicErr.Stack))
+		w.reply(ctx, msg, rec, err, rm)
+		rm.unlock(ctx, msg)
+	case isTimeout(err):
+		// message is kept in queue as well as failure.
+		w.recordTimeout()
+		logger.Warn("invocation timed out. message is treated as failure.", "error", err)
+		w.reply(ctx, msg, rec, err, rm)
+		rm.unlock(ctx, msg)
 	default:
+		// message is kept in queue and its dedup key is released, so that it is retried after visibility timeout.
 		logger.Error("failed to invoke.", "error", err)
+		w.reply(ctx, msg, rec, err, rm)
+		rm.unlock(ctx, msg)
+	}
+}
+
+// reply sends result of invocation to reply queue. Retained or retried message is not replied until it finishes.
+func (w *worker) reply(ctx context.Context, msg Message, rec *responseRecorder, err error, rm remover) {
+	if err := rm.reply(ctx, msg, rec.Body(), err); err != nil {
+		getLogger().Warn("failed to send reply", "message_id", msg.ID, "error", err)
 	}
 }
 
+func (w *worker) recordPanic(msg Message, err *PanicError) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.panics.Count++
+	w.panics.LastAt = time.Now()
+	w.panics.LastMessageID = msg.ID
+	w.panics.LastValue = fmt.Sprint(err.Value)
+}
+
+func (w *worker) recordTimeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timeouts++
+}
+
+// Timeouts returns count of invocations which timed out.
+func (w *worker) Timeouts() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.timeouts
+}
+
+// PanicStats returns panics of invoker which are recovered.
+func (w *worker) PanicStats() panicStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.panics
+}
+
 func (w *worker) RunForProcess(ctx context.Context, broker chan Message, rm remover) {
 	for {
 		select {
@@ -2,6 +2,7 @@ package sqsd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -9,6 +10,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/taiyoh/sqsd/locker"
+	memorylocker "github.com/taiyoh/sqsd/locker/memory"
 )
 
 type testInvoker func(context.Context, Message) error
@@ -61,3 +65,267 @@ func TestWorker(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 	}
 }
+
+type testRemover struct {
+	removed     chan string
+	retried     chan time.Duration
+	deadLetters chan string
+	replies     chan testReply
+	followUps   chan []FollowUpMessage
+	followUpErr error
+	unlocked    chan string
+}
+
+type testReply struct {
+	id   string
+	body string
+	err  error
+}
+
+func (r *testRemover) remove(ctx context.Context, msg Message) error {
+	r.removed <- msg.ID
+	return nil
+}
+
+func (r *testRemover) retryAfter(ctx context.Context, msg Message, delay time.Duration) error {
+	r.retried <- delay
+	return nil
+}
+
+func (r *testRemover) unlock(ctx context.Context, msg Message) {
+	if r.unlocked != nil {
+		r.unlocked <- msg.ID
+	}
+}
+
+func (r *testRemover) deadLetter(ctx context.Context, msg Message) error {
+	r.deadLetters <- msg.ID
+	return nil
+}
+
+func (r *testRemover) reply(ctx context.Context, msg Message, body []byte, invokeErr error) error {
+	if r.replies != nil {
+		r.replies <- testReply{id: msg.ID, body: string(body), err: invokeErr}
+	}
+	return nil
+}
+
+func (r *testRemover) sendFollowUps(ctx context.Context, msg Message, msgs []FollowUpMessage) error {
+	if r.followUps != nil && len(msgs) > 0 {
+		r.followUps <- msgs
+	}
+	return r.followUpErr
+}
+
+func TestWorkerOutcome(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errs := map[string]error{
+		"ok":     nil,
+		"retain": ErrRetainMessage,
+		"retry":  fmt.Errorf("wrapped: %w", &RetryAfterError{Delay: time.Minute}),
+		"dead":   ErrDeadLetter,
+	}
+	ivk := testInvoker(func(ctx context.Context, q Message) error {
+		return errs[q.ID]
+	})
+	rm := &testRemover{
+		removed:     make(chan string, 1),
+		retried:     make(chan time.Duration, 1),
+		deadLetters: make(chan string, 1),
+	}
+	broker := make(chan Message, 1)
+	startWorker(ctx, ivk, broker, rm)
+
+	broker <- Message{ID: "ok"}
+	assert.Equal(t, "ok", <-rm.removed)
+	broker <- Message{ID: "retry"}
+	assert.Equal(t, time.Minute, <-rm.retried)
+	broker <- Message{ID: "dead"}
+	assert.Equal(t, "dead", <-rm.deadLetters)
+
+	broker <- Message{ID: "retain"}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, rm.removed)
+	assert.Empty(t, rm.retried)
+	assert.Empty(t, rm.deadLetters)
+}
+
+// TestWorkerRedelivery checks that message kept in queue is processed again when it is received after failure.
+func TestWorkerRedelivery(t *testing.T) {
+	for label, first := range map[string]func() error{
+		"retry after": func() error { return &RetryAfterError{Delay: time.Minute} },
+		"failure":     func() error { return errors.New("failure") },
+		"timeout":     func() error { return fmt.Errorf("wrapped: %w", context.DeadlineExceeded) },
+		"retain":      func() error { return ErrRetainMessage },
+		"panic":       func() error { panic("boom") },
+	} {
+		t.Run(label, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			invoked := make(chan int, 2)
+			var count int
+			ivk := testInvoker(func(ctx context.Context, q Message) error {
+				count++
+				invoked <- count
+				if count == 1 {
+					return first()
+				}
+				return nil
+			})
+			l := memorylocker.New()
+			gw := &Gateway{locker: l}
+			broker := make(chan Message, 1)
+			w := startWorker(ctx, ivk, broker, gw)
+
+			msg := Message{ID: "id:1", DedupKey: "key:1"}
+			assert.NoError(t, l.Lock(ctx, msg.DedupKey))
+			broker <- msg
+			assert.Equal(t, 1, <-invoked)
+			assert.Eventually(t, func() bool { return len(w.CurrentWorkings(ctx)) == 0 }, time.Second, 10*time.Millisecond)
+
+			// message received again is locked and processed, as well as Gateway does.
+			assert.NoError(t, l.Lock(ctx, msg.DedupKey), "dedup key is released")
+			broker <- msg
+			assert.Equal(t, 2, <-invoked)
+			assert.ErrorIs(t, l.Lock(ctx, msg.DedupKey), locker.ErrQueueExists, "processed message keeps its key")
+		})
+	}
+}
+
+func TestWorkerPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ivk := testInvoker(func(ctx context.Context, q Message) error {
+		if q.ID == "panic" {
+			panic("boom")
+		}
+		return nil
+	})
+	rm := &testRemover{
+		removed:     make(chan string, 1),
+		retried:     make(chan time.Duration, 1),
+		deadLetters: make(chan string, 1),
+	}
+	broker := make(chan Message, 1)
+	w := startWorker(ctx, ivk, broker, rm)
+
+	broker <- Message{ID: "panic"}
+	// worker keeps running after panic.
+	broker <- Message{ID: "ok"}
+	assert.Equal(t, "ok", <-rm.removed)
+	assert.Empty(t, rm.retried)
+
+	stats := w.PanicStats()
+	assert.Equal(t, int64(1), stats.Count)
+	assert.Equal(t, "panic", stats.LastMessageID)
+	assert.Equal(t, "boom", stats.LastValue)
+	assert.False(t, stats.LastAt.IsZero())
+	assert.Empty(t, w.CurrentWorkings(ctx))
+
+	monitor := NewMonitoringService(w)
+	resp, err := monitor.WorkerStatus(ctx, &WorkerStatusRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetPanics())
+	assert.Equal(t, "panic", resp.GetLastPanicMessageId())
+	assert.Equal(t, "boom", resp.GetLastPanic())
+	assert.NotNil(t, resp.GetLastPanicAt())
+}
+
+func TestWorkerTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ivk := Chain(testInvoker(func(ctx context.Context, q Message) error {
+		<-ctx.Done()
+		return fmt.Errorf("wrapped: %w", ctx.Err())
+	}), Timeout(10*time.Millisecond))
+	rm := &testRemover{
+		removed: make(chan string, 1),
+		replies: make(chan testReply, 1),
+	}
+	broker := make(chan Message, 1)
+	w := startWorker(ctx, ivk, broker, rm)
+
+	broker <- Message{ID: "id:1"}
+	reply := <-rm.replies
+	assert.True(t, isTimeout(reply.err))
+	assert.Empty(t, rm.removed)
+	assert.Equal(t, int64(1), w.Timeouts())
+
+	resp, err := NewMonitoringService(w).WorkerStatus(ctx, &WorkerStatusRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetTimeouts())
+}
+
+func TestWorkerReply(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		SetResponse(ctx, []byte(`{"result":"`+q.ID+`"}`))
+		switch q.ID {
+		case "failure":
+			return errors.New("failure")
+		case "retain":
+			return ErrRetainMessage
+		}
+		return nil
+	}), Logging(nil))
+	rm := &testRemover{
+		removed:     make(chan string, 10),
+		retried:     make(chan time.Duration, 10),
+		deadLetters: make(chan string, 10),
+		replies:     make(chan testReply, 10),
+	}
+	broker := make(chan Message, 1)
+	startWorker(ctx, ivk, broker, rm)
+
+	broker <- Message{ID: "ok"}
+	assert.Equal(t, testReply{id: "ok", body: `{"result":"ok"}`}, <-rm.replies)
+	broker <- Message{ID: "failure"}
+	r := <-rm.replies
+	assert.Equal(t, `{"result":"failure"}`, r.body)
+	assert.EqualError(t, r.err, "failure")
+
+	broker <- Message{ID: "retain"}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, rm.replies)
+}
+
+func TestWorkerFollowUps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ivk := InvokerFunc(func(ctx context.Context, q Message) error {
+		AddFollowUp(ctx, FollowUpMessage{QueueURL: "http://localhost/queue/next", Body: string(q.Payload), Delay: time.Second})
+		if q.ID == "failure" {
+			return errors.New("failure")
+		}
+		return nil
+	})
+	rm := &testRemover{
+		removed:     make(chan string, 10),
+		retried:     make(chan time.Duration, 10),
+		deadLetters: make(chan string, 10),
+		followUps:   make(chan []FollowUpMessage, 10),
+		unlocked:    make(chan string, 10),
+	}
+	broker := make(chan Message, 1)
+	startWorker(ctx, ivk, broker, rm)
+
+	broker <- Message{ID: "ok", Payload: []byte("next")}
+	assert.Equal(t, []FollowUpMessage{{QueueURL: "http://localhost/queue/next", Body: "next", Delay: time.Second}}, <-rm.followUps)
+	assert.Equal(t, "ok", <-rm.removed)
+
+	broker <- Message{ID: "failure"}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, rm.followUps, "follow-ups are not sent when invocation fails")
+	assert.Empty(t, rm.removed)
+	assert.Equal(t, "failure", <-rm.unlocked)
+
+	rm.followUpErr = errors.New("send failure")
+	broker <- Message{ID: "unsent", Payload: []byte("next")}
+	<-rm.followUps
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, rm.removed, "message is kept when follow-ups are not sent")
+	assert.Equal(t, "unsent", <-rm.unlocked, "dedup key is released to retry message")
+	assert.Empty(t, rm.unlocked)
+}
@@ -0,0 +1,125 @@
+package sqsd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DedupKey extracts the key which Gateway locks to suppress duplicated messages.
+// Extracted key is also passed to invoker as idempotency key.
+type DedupKey func(*sqs.Message) (string, error)
+
+// ErrDedupKeyNotFound shows that DedupKey can't find key in received message.
+var ErrDedupKeyNotFound = errors.New("dedup key not found")
+
+// DedupByMessageID uses MessageId as dedup key. This is default strategy.
+func DedupByMessageID(msg *sqs.Message) (string, error) {
+	return aws.StringValue(msg.MessageId), nil
+}
+
+// DedupByDeduplicationID uses MessageDeduplicationId attribute of FIFO queue as dedup key.
+func DedupByDeduplicationID(msg *sqs.Message) (string, error) {
+	if v, ok := msg.Attributes[sqs.MessageSystemAttributeNameMessageDeduplicationId]; ok && aws.StringValue(v) != "" {
+		return *v, nil
+	}
+	return "", ErrDedupKeyNotFound
+}
+
+// DedupByBodyHash uses hex encoded SHA-256 hash of message body as dedup key.
+func DedupByBodyHash(msg *sqs.Message) (string, error) {
+	sum := sha256.Sum256([]byte(aws.StringValue(msg.Body)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DedupByAttribute uses string value of supplied message attribute as dedup key.
+func DedupByAttribute(name string) DedupKey {
+	return func(msg *sqs.Message) (string, error) {
+		if v, ok := msg.MessageAttributes[name]; ok && aws.StringValue(v.StringValue) != "" {
+			return *v.StringValue, nil
+		}
+		return "", ErrDedupKeyNotFound
+	}
+}
+
+// DedupByJSONPath uses value of supplied path in JSON body as dedup key.
+// path is dot separated keys such as `$.order.id` or `items.0.id`.
+// if value is not string, its JSON representation is used.
+func DedupByJSONPath(path string) DedupKey {
+	keys := splitJSONPath(path)
+	return func(msg *sqs.Message) (string, error) {
+		var body interface{}
+		if err := json.Unmarshal([]byte(aws.StringValue(msg.Body)), &body); err != nil {
+			return "", err
+		}
+		v, ok := lookupJSONPath(body, keys)
+		if !ok || v == nil {
+			return "", ErrDedupKeyNotFound
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+func lookupJSONPath(v interface{}, keys []string) (interface{}, bool) {
+	for _, key := range keys {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			next, ok := vv[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(vv) {
+				return nil, false
+			}
+			v = vv[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// ParseDedupKey returns DedupKey by its name.
+// Supported names are "message_id", "deduplication_id", "body_hash", "json:<path>" and "attribute:<name>".
+func ParseDedupKey(s string) (DedupKey, error) {
+	switch s {
+	case "", "message_id":
+		return DedupByMessageID, nil
+	case "deduplication_id":
+		return DedupByDeduplicationID, nil
+	case "body_hash":
+		return DedupByBodyHash, nil
+	}
+	if path, ok := strings.CutPrefix(s, "json:"); ok && path != "" {
+		return DedupByJSONPath(path), nil
+	}
+	if name, ok := strings.CutPrefix(s, "attribute:"); ok && name != "" {
+		return DedupByAttribute(name), nil
+	}
+	return nil, fmt.Errorf("unknown dedup key: %s", s)
+}
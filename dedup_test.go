@@ -0,0 +1,57 @@
+package sqsd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupKey(t *testing.T) {
+	msg := &sqs.Message{
+		MessageId: aws.String("id:1"),
+		Body:      aws.String(`{"order":{"id":"o-1","items":[{"sku":100}]}}`),
+		Attributes: map[string]*string{
+			sqs.MessageSystemAttributeNameMessageDeduplicationId: aws.String("dedup-1"),
+		},
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"RequestId": {DataType: aws.String("String"), StringValue: aws.String("req-1")},
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		want string
+		err  error
+	}{
+		{name: "", want: "id:1"},
+		{name: "message_id", want: "id:1"},
+		{name: "deduplication_id", want: "dedup-1"},
+		{name: "body_hash", want: "d5a99ef5a7cc41fb116e84f4a5bc5fb5461b6a9dbdb5f8f24500090c7197a12f"},
+		{name: "json:$.order.id", want: "o-1"},
+		{name: "json:order.items.0.sku", want: "100"},
+		{name: "json:$.order.unknown", err: ErrDedupKeyNotFound},
+		{name: "attribute:RequestId", want: "req-1"},
+		{name: "attribute:Unknown", err: ErrDedupKeyNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := ParseDedupKey(tt.name)
+			assert.NoError(t, err)
+			key, err := fn(msg)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+
+	_, err := ParseDedupKey("json:")
+	assert.EqualError(t, err, "unknown dedup key: json:")
+
+	g := NewGateway(nil, "", FetcherDedupKey(DedupByDeduplicationID))
+	assert.Equal(t, "dedup-1", g.extractDedupKey(msg))
+	assert.Equal(t, "id:2", g.extractDedupKey(&sqs.Message{MessageId: aws.String("id:2")}))
+}
@@ -0,0 +1,163 @@
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ExecInvoker invokes worker process by running command per message.
+// Message payload is written to stdin of the command,
+// and message metadata is supplied by environment variables:
+//
+//   - SQSD_MESSAGE_ID
+//   - SQSD_IDEMPOTENCY_KEY
+//   - SQSD_RECEIVED_AT (RFC3339)
+//   - SQSD_DEADLINE (RFC3339, deadline of invocation)
+//
+// Exit code 0 means success, exit code in retain codes means ErrRetainMessage,
+// and others mean failure.
+type ExecInvoker struct {
+	name        string
+	args        []string
+	timeout     time.Duration
+	env         []string
+	retainCodes map[int]struct{}
+}
+
+// ExecInvokerOption is an option for ExecInvoker.
+type ExecInvokerOption func(*ExecInvoker)
+
+// ExecRetainExitCodes sets exit codes which mean the message should be retained in queue.
+// As default, 75 (EX_TEMPFAIL) is used.
+func ExecRetainExitCodes(codes ...int) ExecInvokerOption {
+	return func(ivk *ExecInvoker) {
+		ivk.retainCodes = make(map[int]struct{}, len(codes))
+		for _, c := range codes {
+			ivk.retainCodes[c] = struct{}{}
+		}
+	}
+}
+
+// ExecEnv adds environment variables such as "KEY=value" to command.
+func ExecEnv(env ...string) ExecInvokerOption {
+	return func(ivk *ExecInvoker) {
+		ivk.env = append(ivk.env, env...)
+	}
+}
+
+// NewExecInvoker returns ExecInvoker instance.
+// When command doesn't finish in dur, its whole process group is killed.
+func NewExecInvoker(command []string, dur time.Duration, opts ...ExecInvokerOption) (*ExecInvoker, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("command is required")
+	}
+	ivk := &ExecInvoker{
+		name:        command[0],
+		args:        command[1:],
+		timeout:     dur,
+		retainCodes: map[int]struct{}{75: {}},
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	return ivk, nil
+}
+
+// Invoke runs command and waits until it finishes.
+func (ivk *ExecInvoker) Invoke(ctx context.Context, q Message) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	logger := getLogger().With("message_id", q.ID)
+	stdout := &logWriter{logger: logger, stream: "stdout"}
+	stderr := &logWriter{logger: logger, stream: "stderr"}
+
+	cmd := exec.CommandContext(ctx, ivk.name, ivk.args...)
+	cmd.Stdin = bytes.NewReader(q.Payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), ivk.env...)
+	cmd.Env = append(cmd.Env,
+		"SQSD_MESSAGE_ID="+q.ID,
+		"SQSD_IDEMPOTENCY_KEY="+q.DedupKey,
+		"SQSD_RECEIVED_AT="+q.ReceivedAt.Format(time.RFC3339),
+	)
+	if deadline, ok := formatDeadline(ctx); ok {
+		cmd.Env = append(cmd.Env, "SQSD_DEADLINE="+deadline)
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// give up reading output of orphaned grandchildren after kill.
+	cmd.WaitDelay = time.Second
+
+	started := time.Now()
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	elapsed := time.Since(started)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Info("command is killed by timeout", "elapsed", elapsed.String())
+		return fmt.Errorf("command timeout: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if _, ok := ivk.retainCodes[code]; ok {
+			logger.Info("command exits with retain code", "exit_code", code, "elapsed", elapsed.String())
+			return ErrRetainMessage
+		}
+		logger.Info("command exits with failure code", "exit_code", code, "elapsed", elapsed.String())
+		return fmt.Errorf("failure exit code: %d", code)
+	}
+	return err
+}
+
+// logWriter writes output of command to logger line by line.
+type logWriter struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	stream string
+	buf    bytes.Buffer
+}
+
+// maxLogLineSize is max length of buffered line. longer line is logged by chunks.
+const maxLogLineSize = 64 * 1024
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			if w.buf.Len() >= maxLogLineSize {
+				w.log(w.buf.Next(maxLogLineSize))
+			}
+			return len(p), nil
+		}
+		w.log(w.buf.Next(i + 1)[:i])
+	}
+}
+
+// Flush logs remaining output which has no line break.
+func (w *logWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.log(w.buf.Next(w.buf.Len()))
+	}
+}
+
+func (w *logWriter) log(line []byte) {
+	w.logger.Info("command output", "stream", w.stream, "line", string(line))
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package sqsd
+
+import "os/exec"
+
+// setProcessGroup does nothing because process group is not supported.
+// Only the command process is killed by killProcessGroup.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills command only.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
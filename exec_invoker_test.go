@@ -0,0 +1,87 @@
+//go:build unix
+
+package sqsd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecInvoker(t *testing.T) {
+	_, err := NewExecInvoker(nil, time.Second)
+	assert.EqualError(t, err, "command is required")
+
+	dir := t.TempDir()
+	script := `
+out="$OUT_DIR/$SQSD_MESSAGE_ID"
+cat > "$out"
+echo "$SQSD_IDEMPOTENCY_KEY" >> "$out"
+echo "stdout line"
+echo "stderr line" >&2
+case "$SQSD_MESSAGE_ID" in
+  retain) exit 75 ;;
+  fail) exit 3 ;;
+  timeout) sleep 10 & sleep 10 ;;
+esac
+`
+	ivk, err := NewExecInvoker([]string{"sh", "-c", script}, 300*time.Millisecond, ExecEnv("OUT_DIR="+dir))
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		id      string
+		wantErr string
+	}{
+		{id: "success"},
+		{id: "retain", wantErr: ErrRetainMessage.Error()},
+		{id: "fail", wantErr: "failure exit code: 3"},
+		{id: "timeout", wantErr: "command timeout: context deadline exceeded"},
+	} {
+		t.Run(tt.id, func(t *testing.T) {
+			started := time.Now()
+			err := ivk.Invoke(context.Background(), Message{
+				ID:       tt.id,
+				Payload:  []byte(`{"foo":"bar"}`),
+				DedupKey: "key:" + tt.id,
+			})
+			assert.Less(t, time.Since(started), 2*time.Second)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+			b, err := os.ReadFile(filepath.Join(dir, tt.id))
+			assert.NoError(t, err)
+			assert.Equal(t, "{\"foo\":\"bar\"}key:"+tt.id+"\n", string(b))
+		})
+	}
+
+	ivk, err = NewExecInvoker([]string{"sh", "-c", "exit 75"}, time.Second, ExecRetainExitCodes(10))
+	assert.NoError(t, err)
+	assert.EqualError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}), "failure exit code: 75")
+}
+
+func TestExecInvokerDeadline(t *testing.T) {
+	dir := t.TempDir()
+	script := `sleep 0.1; printf %s "$SQSD_DEADLINE" > "$OUT_DIR/$SQSD_MESSAGE_ID"`
+	// 0 means no timeout except for deadline of message.
+	ivk, err := NewExecInvoker([]string{"sh", "-c", script}, 0, ExecEnv("OUT_DIR="+dir))
+	assert.NoError(t, err)
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "unlimited"}))
+	b, err := os.ReadFile(filepath.Join(dir, "unlimited"))
+	assert.NoError(t, err)
+	assert.Empty(t, string(b))
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "limited"}))
+	b, err = os.ReadFile(filepath.Join(dir, "limited"))
+	assert.NoError(t, err)
+	assert.Equal(t, deadline.UTC().Format(time.RFC3339Nano), string(b))
+}
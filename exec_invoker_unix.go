@@ -0,0 +1,19 @@
+//go:build unix
+
+package sqsd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs command in new process group,
+// so that its children are killed together by killProcessGroup.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills command and its children.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,272 @@
+package sqsd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FastCGIInvoker invokes worker process by FastCGI request, such as php-fpm.
+type FastCGIInvoker struct {
+	network        string
+	address        string
+	scriptFilename string
+	timeout        time.Duration
+	dialer         net.Dialer
+}
+
+// NewFastCGIInvoker returns FastCGIInvoker instance.
+// rawaddr is "tcp://host:port" or "unix:///path/to/socket",
+// and scriptFilename is sent as SCRIPT_FILENAME param.
+func NewFastCGIInvoker(rawaddr, scriptFilename string, dur time.Duration) (*FastCGIInvoker, error) {
+	u, err := url.Parse(rawaddr)
+	if err != nil {
+		return nil, err
+	}
+	ivk := &FastCGIInvoker{
+		network:        u.Scheme,
+		scriptFilename: scriptFilename,
+		timeout:        dur,
+	}
+	switch u.Scheme {
+	case "tcp":
+		ivk.address = u.Host
+	case "unix":
+		ivk.address = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if ivk.address == "" {
+		return nil, errors.New("address is required")
+	}
+	if scriptFilename == "" {
+		return nil, errors.New("script filename is required")
+	}
+	return ivk, nil
+}
+
+// Invoke sends message payload as request body by FastCGI.
+// Status header of response is handled as well as HTTPInvoker, and response body is sent to reply queue if it is configured.
+func (ivk *FastCGIInvoker) Invoke(ctx context.Context, q Message) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	conn, err := ivk.dialer.DialContext(ctx, ivk.network, ivk.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	status, body, err := doFastCGI(conn, ivk.params(ctx, q), q.Payload)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("fastcgi request: %w", ctx.Err())
+		}
+		return err
+	}
+	SetResponse(ctx, body)
+	return resultByStatus(status, bytes.NewReader(body))
+}
+
+func (ivk *FastCGIInvoker) params(ctx context.Context, q Message) map[string]string {
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "sqsd",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"REQUEST_METHOD":    http.MethodPost,
+		"REQUEST_URI":       "/",
+		"SCRIPT_NAME":       "/",
+		"SCRIPT_FILENAME":   ivk.scriptFilename,
+		"CONTENT_LENGTH":    strconv.Itoa(len(q.Payload)),
+	}
+	h := invocationHeader(q)
+	setDeadlineHeader(ctx, h)
+	for k, vs := range h {
+		name := "HTTP_" + strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if k == "Content-Type" {
+			name = "CONTENT_TYPE"
+		}
+		params[name] = strings.Join(vs, ", ")
+	}
+	return params
+}
+
+// FastCGI record types and role. see https://fastcgi-archives.github.io/FastCGI_Specification.html
+const (
+	fcgiVersion      = 1
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+	fcgiResponder    = 1
+	fcgiRequestID    = 1
+	fcgiMaxContent   = 65535
+)
+
+// fcgiProtocolStatuses are names of protocolStatus in FCGI_END_REQUEST except for FCGI_REQUEST_COMPLETE (0).
+var fcgiProtocolStatuses = map[uint8]string{
+	1: "FCGI_CANT_MPX_CONN",
+	2: "FCGI_OVERLOADED",
+	3: "FCGI_UNKNOWN_ROLE",
+}
+
+func writeFastCGIRecord(w io.Writer, recType uint8, content []byte) error {
+	padding := (8 - len(content)%8) % 8
+	header := [8]byte{fcgiVersion, recType}
+	binary.BigEndian.PutUint16(header[2:4], fcgiRequestID)
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(content)))
+	header[6] = uint8(padding)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	_, err := w.Write(make([]byte, padding))
+	return err
+}
+
+// writeFastCGIStream writes content by chunked records and empty record for end of stream.
+func writeFastCGIStream(w io.Writer, recType uint8, content []byte) error {
+	for len(content) > 0 {
+		n := min(len(content), fcgiMaxContent)
+		if err := writeFastCGIRecord(w, recType, content[:n]); err != nil {
+			return err
+		}
+		content = content[n:]
+	}
+	return writeFastCGIRecord(w, recType, nil)
+}
+
+func encodeFastCGILength(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|1<<31)
+	buf.Write(b[:])
+}
+
+func encodeFastCGIParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	for k, v := range params {
+		encodeFastCGILength(&buf, len(k))
+		encodeFastCGILength(&buf, len(v))
+		buf.WriteString(k)
+		buf.WriteString(v)
+	}
+	return buf.Bytes()
+}
+
+// doFastCGI sends request as responder role and returns status and body of CGI response.
+func doFastCGI(conn io.ReadWriter, params map[string]string, body []byte) (int, []byte, error) {
+	w := bufio.NewWriter(conn)
+	// role and flags (no keep-alive) and 5 reserved bytes.
+	begin := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}
+	if err := writeFastCGIRecord(w, fcgiBeginRequest, begin); err != nil {
+		return 0, nil, err
+	}
+	if err := writeFastCGIStream(w, fcgiParams, encodeFastCGIParams(params)); err != nil {
+		return 0, nil, err
+	}
+	if err := writeFastCGIStream(w, fcgiStdin, body); err != nil {
+		return 0, nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	r := bufio.NewReader(conn)
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return 0, nil, fmt.Errorf("failed to read fastcgi response: %w", err)
+		}
+		content := make([]byte, int(binary.BigEndian.Uint16(header[4:6]))+int(header[6]))
+		if _, err := io.ReadFull(r, content); err != nil {
+			return 0, nil, fmt.Errorf("failed to read fastcgi response: %w", err)
+		}
+		content = content[:len(content)-int(header[6])]
+		if id := binary.BigEndian.Uint16(header[2:4]); id != fcgiRequestID {
+			return 0, nil, fmt.Errorf("unexpected fastcgi request id: %d", id)
+		}
+		switch header[1] {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			if stderr.Len() > 0 {
+				getLogger().Info("fastcgi stderr", "body", stderr.String())
+			}
+			if err := checkFastCGIEndRequest(content, stdout.Len()); err != nil {
+				return 0, nil, err
+			}
+			return parseCGIResponse(&stdout)
+		}
+	}
+}
+
+// checkFastCGIEndRequest returns error if request is not completed by application,
+// or application exits without output. Empty output is not treated as success, because the application may crash.
+func checkFastCGIEndRequest(body []byte, outputLen int) error {
+	if len(body) < 5 {
+		return errors.New("invalid fastcgi end request")
+	}
+	appStatus := binary.BigEndian.Uint32(body[0:4])
+	if protocolStatus := body[4]; protocolStatus != 0 {
+		name, ok := fcgiProtocolStatuses[protocolStatus]
+		if !ok {
+			name = strconv.Itoa(int(protocolStatus))
+		}
+		return fmt.Errorf("fastcgi request is not completed: %s", name)
+	}
+	if outputLen > 0 {
+		return nil
+	}
+	if appStatus != 0 {
+		return fmt.Errorf("fastcgi application exits with status %d without output", appStatus)
+	}
+	return errors.New("empty fastcgi response")
+}
+
+// parseCGIResponse returns status from Status header. if Status header doesn't exist, 200 is returned.
+func parseCGIResponse(r io.Reader) (int, []byte, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, fmt.Errorf("invalid cgi response header: %w", err)
+	}
+	body, err := io.ReadAll(tp.R)
+	if err != nil {
+		return 0, nil, err
+	}
+	status := http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		code, _, _ := strings.Cut(s, " ")
+		if status, err = strconv.Atoi(code); err != nil {
+			return 0, nil, fmt.Errorf("invalid status header: %s", s)
+		}
+	}
+	return status, body, nil
+}
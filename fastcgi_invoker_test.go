@@ -0,0 +1,182 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastCGIInvoker(t *testing.T) {
+	for _, tt := range []struct {
+		addr    string
+		wantErr string
+	}{
+		{addr: "http://localhost:9000", wantErr: "unsupported scheme: http"},
+		{addr: "tcp://", wantErr: "address is required"},
+	} {
+		_, err := NewFastCGIInvoker(tt.addr, "/app/index.php", time.Second)
+		assert.EqualError(t, err, tt.wantErr)
+	}
+
+	type received struct {
+		header http.Header
+		env    map[string]string
+	}
+	receivedCh := make(chan received, 10)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &invokerTestPayload{}
+		_ = json.NewDecoder(r.Body).Decode(p)
+		receivedCh <- received{header: r.Header, env: fcgi.ProcessEnv(r)}
+		time.Sleep(p.Sleep)
+		w.WriteHeader(p.Status)
+		_, _ = w.Write([]byte("response body"))
+	})
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unixListener, err := net.Listen("unix", filepath.Join(t.TempDir(), "fpm.sock"))
+	assert.NoError(t, err)
+	for _, l := range []net.Listener{tcpListener, unixListener} {
+		go func(l net.Listener) { _ = fcgi.Serve(l, handler) }(l)
+		t.Cleanup(func() { l.Close() })
+	}
+
+	for _, addr := range []string{
+		"tcp://" + tcpListener.Addr().String(),
+		"unix://" + unixListener.Addr().String(),
+	} {
+		ivk, err := NewFastCGIInvoker(addr, "/app/index.php", 300*time.Millisecond)
+		assert.NoError(t, err)
+
+		for _, tt := range []struct {
+			label       string
+			status      int
+			sleep       int64
+			expectedErr bool
+		}{
+			{label: "200", status: http.StatusOK},
+			{label: "400", status: http.StatusBadRequest},
+			{label: "500", status: http.StatusInternalServerError, expectedErr: true},
+			{label: "timeout", status: http.StatusOK, sleep: 500, expectedErr: true},
+		} {
+			t.Run(addr+" "+tt.label, func(t *testing.T) {
+				b, _ := json.Marshal(invokerTestPayload{
+					Status: tt.status,
+					Sleep:  time.Duration(tt.sleep) * time.Millisecond,
+				})
+				err := ivk.Invoke(context.Background(), Message{
+					ID:       "id:1",
+					Payload:  b,
+					DedupKey: "key:1",
+				})
+				if tt.expectedErr {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+				r := <-receivedCh
+				assert.Equal(t, "id:1", r.header.Get("X-Aws-Sqsd-Msgid"))
+				assert.Equal(t, "key:1", r.header.Get("X-Aws-Sqsd-Idempotency-Key"))
+				assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+				assert.Equal(t, "/app/index.php", r.env["SCRIPT_FILENAME"])
+			})
+		}
+	}
+}
+
+// serveFastCGIRecords reads request until end of stdin, and writes records as response.
+func serveFastCGIRecords(t *testing.T, conn net.Conn, records func(w io.Writer)) {
+	defer conn.Close()
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			t.Error(err)
+			return
+		}
+		n := int(binary.BigEndian.Uint16(header[4:6])) + int(header[6])
+		if _, err := io.ReadFull(conn, make([]byte, n)); err != nil {
+			t.Error(err)
+			return
+		}
+		if header[1] == fcgiStdin && n == 0 {
+			break
+		}
+	}
+	records(conn)
+}
+
+func fastCGIEndRequest(appStatus uint32, protocolStatus uint8) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, appStatus)
+	b[4] = protocolStatus
+	return b
+}
+
+func TestDoFastCGIEndRequest(t *testing.T) {
+	for _, tt := range []struct {
+		label   string
+		records func(w io.Writer)
+		wantErr string
+	}{
+		{
+			label: "complete",
+			records: func(w io.Writer) {
+				_ = writeFastCGIRecord(w, fcgiStdout, []byte("Status: 200 OK\r\n\r\nok"))
+				_ = writeFastCGIRecord(w, fcgiEndRequest, fastCGIEndRequest(0, 0))
+			},
+		},
+		{
+			label: "overloaded",
+			records: func(w io.Writer) {
+				_ = writeFastCGIRecord(w, fcgiEndRequest, fastCGIEndRequest(0, 2))
+			},
+			wantErr: "fastcgi request is not completed: FCGI_OVERLOADED",
+		},
+		{
+			label: "crashed",
+			records: func(w io.Writer) {
+				_ = writeFastCGIRecord(w, fcgiEndRequest, fastCGIEndRequest(255, 0))
+			},
+			wantErr: "fastcgi application exits with status 255 without output",
+		},
+		{
+			label: "empty",
+			records: func(w io.Writer) {
+				_ = writeFastCGIRecord(w, fcgiEndRequest, fastCGIEndRequest(0, 0))
+			},
+			wantErr: "empty fastcgi response",
+		},
+		{
+			label: "other request",
+			records: func(w io.Writer) {
+				header := [8]byte{fcgiVersion, fcgiEndRequest, 0, 2, 0, 8}
+				_, _ = w.Write(append(header[:], fastCGIEndRequest(0, 0)...))
+			},
+			wantErr: "unexpected fastcgi request id: 2",
+		},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go serveFastCGIRecords(t, server, tt.records)
+
+			status, body, err := doFastCGI(client, map[string]string{"SCRIPT_FILENAME": "/app/index.php"}, []byte(`{}`))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "ok", string(body))
+		})
+	}
+}
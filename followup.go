@@ -0,0 +1,66 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FollowUpMessage is a message which worker emits to enqueue next step of pipeline.
+// It is sent before received message is removed, at least once: it can be duplicated when received message is retried.
+type FollowUpMessage struct {
+	QueueURL   string            `json:"queueUrl"`
+	Body       string            `json:"body"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Delay      time.Duration     `json:"-"`
+}
+
+// UnmarshalJSON parses follow-up message in response body of worker.
+// Delay is given by "delaySeconds".
+func (m *FollowUpMessage) UnmarshalJSON(b []byte) error {
+	type plain FollowUpMessage
+	var v struct {
+		plain
+		DelaySeconds int64 `json:"delaySeconds"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*m = FollowUpMessage(v.plain)
+	m.Delay = time.Duration(v.DelaySeconds) * time.Second
+	return nil
+}
+
+// AddFollowUp adds follow-up messages to context of invocation.
+// They are sent only when invocation succeeds. It does nothing if ctx is not for invocation.
+func AddFollowUp(ctx context.Context, msgs ...FollowUpMessage) {
+	rec := responseRecorderFrom(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.followUps = append(rec.followUps, msgs...)
+}
+
+// parseFollowUps reads "followUps" field of JSON response body.
+// Body which is not JSON object is ignored because it may be response only for reply queue.
+func parseFollowUps(body []byte) ([]FollowUpMessage, error) {
+	var res struct {
+		FollowUps json.RawMessage `json:"followUps"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || len(res.FollowUps) == 0 {
+		return nil, nil
+	}
+	var msgs []FollowUpMessage
+	if err := json.Unmarshal(res.FollowUps, &msgs); err != nil {
+		return nil, fmt.Errorf("invalid follow-up messages: %w", err)
+	}
+	for i, m := range msgs {
+		if m.QueueURL == "" {
+			return nil, fmt.Errorf("invalid follow-up messages: queueUrl is required at %d", i)
+		}
+	}
+	return msgs, nil
+}
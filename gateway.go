@@ -2,6 +2,10 @@ package sqsd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +25,14 @@ type Gateway struct {
 	fetcherInterval time.Duration
 	parallel        int
 	input           *sqs.ReceiveMessageInput
+	failurePolicy   LockFailurePolicy
+	failurePause    time.Duration
+	dedupKey        DedupKey
+	deadLetterURL   string
+	replyURL        string
+	replyAttribute  string
+	// visibilityTimeout is VisibilityTimeout of receiving message request.
+	visibilityTimeout time.Duration
 }
 
 type gatewayParams struct {
@@ -30,6 +42,50 @@ type gatewayParams struct {
 	numberOfMessages int64
 	parallel         int
 	locker           locker.QueueLocker
+	failurePolicy    LockFailurePolicy
+	failurePause     time.Duration
+	dedupKey         DedupKey
+	deadLetterURL    string
+	replyURL         string
+	replyAttribute   string
+}
+
+// LockFailurePolicy decides how Gateway handles received message when locker fails except for duplication.
+type LockFailurePolicy int
+
+const (
+	// LockFailureSkip skips message, so it is kept invisible until its visibility timeout expires.
+	LockFailureSkip LockFailurePolicy = iota
+	// LockFailureOpen passes message to consumer without lock.
+	LockFailureOpen
+	// LockFailureClosed makes message visible immediately and pauses fetching.
+	LockFailureClosed
+)
+
+// UnmarshalText parses policy name: "skip", "open" or "closed".
+func (p *LockFailurePolicy) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "skip":
+		*p = LockFailureSkip
+	case "open":
+		*p = LockFailureOpen
+	case "closed":
+		*p = LockFailureClosed
+	default:
+		return fmt.Errorf("unknown lock failure policy: %s", b)
+	}
+	return nil
+}
+
+// String returns policy name.
+func (p LockFailurePolicy) String() string {
+	switch p {
+	case LockFailureOpen:
+		return "open"
+	case LockFailureClosed:
+		return "closed"
+	}
+	return "skip"
 }
 
 // NewGateway returns Gateway object.
@@ -41,22 +97,35 @@ func NewGateway(queue *sqs.SQS, queueURL string, params ...GatewayParameter) *Ga
 		numberOfMessages: 10,
 		parallel:         1,
 		locker:           nooplocker.Get(),
+		failurePolicy:    LockFailureSkip,
+		failurePause:     5 * time.Second,
+		dedupKey:         DedupByMessageID,
+		replyAttribute:   "ResponseQueueUrl",
 	}
 	for _, fn := range params {
 		fn(&param)
 	}
 
 	return &Gateway{
-		queue:           queue,
-		queueURL:        queueURL,
-		fetcherInterval: param.fetcherInterval,
-		locker:          nooplocker.Get(),
-		parallel:        param.parallel,
+		queue:             queue,
+		queueURL:          queueURL,
+		fetcherInterval:   param.fetcherInterval,
+		locker:            param.locker,
+		parallel:          param.parallel,
+		failurePolicy:     param.failurePolicy,
+		failurePause:      param.failurePause,
+		dedupKey:          param.dedupKey,
+		deadLetterURL:     param.deadLetterURL,
+		replyURL:          param.replyURL,
+		replyAttribute:    param.replyAttribute,
+		visibilityTimeout: time.Duration(param.timeout) * time.Second,
 		input: &sqs.ReceiveMessageInput{
-			QueueUrl:            &queueURL,
-			MaxNumberOfMessages: &param.numberOfMessages,
-			WaitTimeSeconds:     aws.Int64(param.waitTime),
-			VisibilityTimeout:   aws.Int64(param.timeout),
+			QueueUrl:              &queueURL,
+			MaxNumberOfMessages:   &param.numberOfMessages,
+			WaitTimeSeconds:       aws.Int64(param.waitTime),
+			VisibilityTimeout:     aws.Int64(param.timeout),
+			AttributeNames:        aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+			MessageAttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
 		},
 	}
 }
@@ -78,6 +147,14 @@ func FetcherWaitTime(d time.Duration) GatewayParameter {
 	}
 }
 
+// FetcherVisibilityTimeout sets VisibilityTimeout of receiving message request. As default, it is 30 seconds.
+// Message becomes visible again after it unless it is removed, which is Message.VisibleAt.
+func FetcherVisibilityTimeout(d time.Duration) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.timeout = int64(d.Seconds())
+	}
+}
+
 // FetcherQueueLocker sets FetcherQueueLocker in Gateway to block duplicated queue.
 func FetcherQueueLocker(l locker.QueueLocker) GatewayParameter {
 	return func(g *gatewayParams) {
@@ -85,6 +162,48 @@ func FetcherQueueLocker(l locker.QueueLocker) GatewayParameter {
 	}
 }
 
+// FetcherLockFailurePolicy sets policy for failure of locker.
+// pause is used only by LockFailureClosed, which is duration to stop fetching after messages are released.
+func FetcherLockFailurePolicy(p LockFailurePolicy, pause time.Duration) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.failurePolicy = p
+		g.failurePause = pause
+	}
+}
+
+// FetcherDedupKey sets strategy of key to lock received message.
+// As default, MessageId is used.
+func FetcherDedupKey(fn DedupKey) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.dedupKey = fn
+	}
+}
+
+// FetcherDeadLetterQueue sets URL of queue to which message is moved by ErrDeadLetter.
+// If it is not supplied, message moved to dead-letter queue is kept in source queue,
+// so that it is expected to be moved by redrive policy of source queue.
+func FetcherDeadLetterQueue(queueURL string) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.deadLetterURL = queueURL
+	}
+}
+
+// FetcherReplyQueue sets URL of queue to which result of invocation is sent,
+// when received message doesn't have reply queue in its attribute.
+func FetcherReplyQueue(queueURL string) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.replyURL = queueURL
+	}
+}
+
+// FetcherReplyAttribute sets name of message attribute which has URL of reply queue.
+// As default, "ResponseQueueUrl" is used.
+func FetcherReplyAttribute(name string) GatewayParameter {
+	return func(g *gatewayParams) {
+		g.replyAttribute = name
+	}
+}
+
 // FetcherMaxMessages sets MaxNumberOfMessages of SQS between 1 and 10.
 // Fetcher's default value is 10.
 // if supplied value is out of range, forcely sets 1 or 10.
@@ -134,27 +253,214 @@ func (f *Gateway) runForFetch(ctx context.Context, wg *sync.WaitGroup, broker ch
 			logger.Error("failed to fetch from SQS", "error", err)
 		}
 		receivedAt := time.Now().UTC()
+		var paused bool
 		for _, msg := range out.Messages {
-			if err := f.locker.Lock(ctx, *msg.MessageId); err != nil {
-				if err == locker.ErrQueueExists {
-					logger.Warn("received message is duplicated", "message_id", *msg.MessageId)
-				} else {
-					logger.Error("failed to lock", "error", err)
-				}
-				continue
-			}
-			broker <- Message{
+			m := Message{
 				ID:         *msg.MessageId,
-				Payload:    *msg.Body,
+				Payload:    []byte(*msg.Body),
 				Receipt:    *msg.ReceiptHandle,
 				ReceivedAt: receivedAt,
+				SentAt:     sentAt(msg),
+				DedupKey:   f.extractDedupKey(msg),
+				Attributes: messageAttributes(msg),
+				VisibleAt:  receivedAt.Add(f.visibilityTimeout),
 			}
+			if err := f.locker.Lock(ctx, m.DedupKey); err != nil {
+				if err == locker.ErrQueueExists {
+					logger.Warn("received message is duplicated", "message_id", m.ID, "dedup_key", m.DedupKey)
+					continue
+				}
+				if !f.handleLockFailure(ctx, m, err) {
+					paused = paused || f.failurePolicy == LockFailureClosed
+					continue
+				}
+			}
+			broker <- m
 		}
 		logger.Debug("caught messages.", "length", len(out.Messages))
+		if paused {
+			logger.Warn("pause fetching by lock failure.", "duration", f.failurePause.String())
+			sleepWithContext(ctx, f.failurePause)
+		}
 		time.Sleep(f.fetcherInterval)
 	}
 }
 
+// messageAttributes returns message attributes which have string value.
+// Binary attributes are ignored.
+func messageAttributes(msg *sqs.Message) map[string]string {
+	if len(msg.MessageAttributes) == 0 {
+		return nil
+	}
+	attrs := make(map[string]string, len(msg.MessageAttributes))
+	for k, v := range msg.MessageAttributes {
+		if v.StringValue != nil {
+			attrs[k] = *v.StringValue
+		}
+	}
+	return attrs
+}
+
+// sentAt returns time of SentTimestamp attribute. zero is returned if it is missing.
+func sentAt(msg *sqs.Message) time.Time {
+	ms, err := strconv.ParseInt(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+// extractDedupKey returns MessageId if dedup key can't be extracted from message.
+func (f *Gateway) extractDedupKey(msg *sqs.Message) string {
+	key, err := f.dedupKey(msg)
+	if err != nil || key == "" {
+		getLogger().Warn("failed to extract dedup key. use message id instead.", "message_id", *msg.MessageId, "error", err)
+		return *msg.MessageId
+	}
+	return key
+}
+
+// handleLockFailure reports whether the message should be passed to consumer.
+func (f *Gateway) handleLockFailure(ctx context.Context, msg Message, err error) bool {
+	logger := getLogger().With("message_id", msg.ID, "policy", f.failurePolicy.String())
+	switch f.failurePolicy {
+	case LockFailureOpen:
+		logger.Warn("failed to lock. message is processed without lock.", "error", err)
+		return true
+	case LockFailureClosed:
+		logger.Error("failed to lock. message is released.", "error", err)
+		if err := f.release(ctx, msg); err != nil {
+			logger.Error("failed to release message", "error", err)
+		}
+	default:
+		logger.Error("failed to lock", "error", err)
+	}
+	return false
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// release makes message visible immediately by changing its visibility timeout to 0.
+func (g *Gateway) release(ctx context.Context, msg Message) error {
+	return g.retryAfter(ctx, msg, 0)
+}
+
+// unlock releases dedup key of message, so that the message is not dropped as duplicated when it is received again.
+// If locker doesn't implement locker.KeyReleaser, the key is kept until it expires.
+func (g *Gateway) unlock(ctx context.Context, msg Message) {
+	r, ok := g.locker.(locker.KeyReleaser)
+	if !ok || msg.DedupKey == "" {
+		return
+	}
+	if err := r.Release(ctx, msg.DedupKey); err != nil && !errors.Is(err, locker.ErrLockNotFound) {
+		getLogger().Warn("failed to release dedup key", "message_id", msg.ID, "dedup_key", msg.DedupKey, "error", err)
+	}
+}
+
+// maxVisibilityTimeout is the max visibility timeout which SQS accepts.
+const maxVisibilityTimeout = 12 * time.Hour
+
+// retryAfter makes message visible after delay by changing its visibility timeout.
+// delay is truncated to seconds and capped by 12 hours.
+// Dedup key of message is released beforehand, so that the message is processed again after delay.
+func (g *Gateway) retryAfter(ctx context.Context, msg Message, delay time.Duration) error {
+	g.unlock(ctx, msg)
+	// in some tests, queue object is empty for nothing to do it.
+	if g.queue == nil {
+		return nil
+	}
+	delay = min(max(delay, 0), maxVisibilityTimeout)
+	_, err := g.queue.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &g.queueURL,
+		ReceiptHandle:     &msg.Receipt,
+		VisibilityTimeout: aws.Int64(int64(delay.Seconds())),
+	})
+	return err
+}
+
+// errDeadLetterNotConfigured is returned by deadLetter when dead-letter queue is not supplied.
+var errDeadLetterNotConfigured = errors.New("dead-letter queue is not configured")
+
+// deadLetter sends message to dead-letter queue, and removes it from source queue.
+// Message attributes are sent as String type.
+func (g *Gateway) deadLetter(ctx context.Context, msg Message) error {
+	if g.deadLetterURL == "" {
+		return errDeadLetterNotConfigured
+	}
+	// in some tests, queue object is empty for nothing to do it.
+	if g.queue == nil {
+		return nil
+	}
+	attrs := make(map[string]*sqs.MessageAttributeValue, len(msg.Attributes))
+	for k, v := range msg.Attributes {
+		attrs[k] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	if _, err := g.queue.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:          &g.deadLetterURL,
+		MessageBody:       aws.String(string(msg.Payload)),
+		MessageAttributes: attrs,
+	}); err != nil {
+		return err
+	}
+	return g.remove(ctx, msg)
+}
+
+// reply sends response body of worker to reply queue of message with attributes:
+//
+//   - CorrelationId: ID of message
+//   - Status: "success", "failure" or "timeout"
+//   - Error: error of invocation (only for failure)
+//
+// If message has no reply queue, nothing is sent. Empty body is sent as "{}" because SQS rejects empty message.
+func (g *Gateway) reply(ctx context.Context, msg Message, body []byte, invokeErr error) error {
+	queueURL := g.replyURL
+	if u, ok := msg.Attributes[g.replyAttribute]; ok && u != "" {
+		queueURL = u
+	}
+	if queueURL == "" {
+		return nil
+	}
+	// in some tests, queue object is empty for nothing to do it.
+	if g.queue == nil {
+		return nil
+	}
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	attrs := map[string]*sqs.MessageAttributeValue{
+		"CorrelationId": {DataType: aws.String("String"), StringValue: aws.String(msg.ID)},
+		"Status":        {DataType: aws.String("String"), StringValue: aws.String("success")},
+	}
+	if invokeErr != nil {
+		attrs["Status"].StringValue = aws.String("failure")
+		if isTimeout(invokeErr) {
+			attrs["Status"].StringValue = aws.String("timeout")
+		}
+		attrs["Error"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(invokeErr.Error())}
+	}
+	input := &sqs.SendMessageInput{
+		QueueUrl:          &queueURL,
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: attrs,
+	}
+	if strings.HasSuffix(queueURL, ".fifo") {
+		input.MessageGroupId = aws.String(msg.ID)
+		input.MessageDeduplicationId = aws.String(msg.ID)
+	}
+	_, err := g.queue.SendMessageWithContext(ctx, input)
+	return err
+}
+
 // Remove sends delete-message to SQS.
 func (g *Gateway) remove(ctx context.Context, msg Message) (err error) {
 	// in some tests, queue object is empty for nothing to do it.
@@ -177,3 +483,68 @@ func (g *Gateway) remove(ctx context.Context, msg Message) (err error) {
 	}
 	return err
 }
+
+// maxDelay is the max delay of message which SQS accepts.
+const maxDelay = 15 * time.Minute
+
+// maxBatchEntries is the max count of entries in SendMessageBatch.
+const maxBatchEntries = 10
+
+// sendFollowUps sends follow-up messages by SendMessageBatch per destination queue.
+// It returns error if any of them is not sent, so that received message is not removed and retried.
+// Follow-ups are delivered at least once, because ones already sent are sent again by the retry.
+// For FIFO queue, received message ID is used as MessageGroupId,
+// and deduplication ID is derived from it so that retried follow-ups are deduplicated by SQS.
+func (g *Gateway) sendFollowUps(ctx context.Context, msg Message, msgs []FollowUpMessage) error {
+	// in some tests, queue object is empty for nothing to do it.
+	if g.queue == nil || len(msgs) == 0 {
+		return nil
+	}
+	var queueURLs []string
+	entries := map[string][]*sqs.SendMessageBatchRequestEntry{}
+	for i, m := range msgs {
+		entry := &sqs.SendMessageBatchRequestEntry{
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(m.Body),
+		}
+		if len(m.Attributes) > 0 {
+			entry.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(m.Attributes))
+			for k, v := range m.Attributes {
+				entry.MessageAttributes[k] = &sqs.MessageAttributeValue{
+					DataType:    aws.String("String"),
+					StringValue: aws.String(v),
+				}
+			}
+		}
+		if strings.HasSuffix(m.QueueURL, ".fifo") {
+			entry.MessageGroupId = aws.String(msg.ID)
+			entry.MessageDeduplicationId = aws.String(fmt.Sprintf("%s-%d", msg.ID, i))
+		} else if m.Delay > 0 {
+			entry.DelaySeconds = aws.Int64(int64(min(m.Delay, maxDelay) / time.Second))
+		}
+		if _, ok := entries[m.QueueURL]; !ok {
+			queueURLs = append(queueURLs, m.QueueURL)
+		}
+		entries[m.QueueURL] = append(entries[m.QueueURL], entry)
+	}
+	for _, queueURL := range queueURLs {
+		queueEntries := entries[queueURL]
+		for len(queueEntries) > 0 {
+			n := min(len(queueEntries), maxBatchEntries)
+			out, err := g.queue.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+				QueueUrl: aws.String(queueURL),
+				Entries:  queueEntries[:n],
+			})
+			if err != nil {
+				return err
+			}
+			if len(out.Failed) > 0 {
+				f := out.Failed[0]
+				return fmt.Errorf("failed to send %d follow-up messages to %s: %s",
+					len(out.Failed), queueURL, aws.StringValue(f.Message))
+			}
+			queueEntries = queueEntries[n:]
+		}
+	}
+	return nil
+}
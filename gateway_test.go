@@ -2,14 +2,21 @@ package sqsd
 
 import (
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +76,155 @@ func TestFetcherAndRemover(t *testing.T) {
 
 	assert.Equal(t, int32(20), removed)
 }
+
+func TestGatewayLockFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+	msg := Message{ID: "id:1", Receipt: "receipt"}
+	errLock := errors.New("failed to connect")
+
+	for _, tt := range []struct {
+		policy string
+		want   bool
+	}{
+		{policy: "skip", want: false},
+		{policy: "open", want: true},
+		{policy: "closed", want: false},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			var p LockFailurePolicy
+			assert.NoError(t, p.UnmarshalText([]byte(tt.policy)))
+			assert.Equal(t, tt.policy, p.String())
+			g := NewGateway(nil, "", FetcherLockFailurePolicy(p, time.Second))
+			assert.Equal(t, tt.want, g.handleLockFailure(ctx, msg, errLock))
+		})
+	}
+
+	var p LockFailurePolicy
+	assert.EqualError(t, p.UnmarshalText([]byte("unknown")), "unknown lock failure policy: unknown")
+}
+
+// newFakeSQS returns SQS client whose requests are sent to channel.
+// SendMessage, SendMessageBatch, DeleteMessage and ChangeMessageVisibility are supported.
+func newFakeSQS(t *testing.T) (*sqs.SQS, chan url.Values) {
+	reqCh := make(chan url.Values, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		reqCh <- r.PostForm
+		action := r.PostForm.Get("Action")
+		result := ""
+		if action == "SendMessage" {
+			sum := md5.Sum([]byte(r.PostForm.Get("MessageBody")))
+			result = fmt.Sprintf("<SendMessageResult><MD5OfMessageBody>%x</MD5OfMessageBody><MessageId>sent</MessageId></SendMessageResult>", sum)
+		}
+		if action == "SendMessageBatch" {
+			result = "<SendMessageBatchResult>"
+			for i := 1; r.PostForm.Has(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)); i++ {
+				sum := md5.Sum([]byte(r.PostForm.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.MessageBody", i))))
+				result += fmt.Sprintf("<SendMessageBatchResultEntry><Id>%s</Id><MD5OfMessageBody>%x</MD5OfMessageBody><MessageId>sent</MessageId></SendMessageBatchResultEntry>",
+					r.PostForm.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)), sum)
+			}
+			result += "</SendMessageBatchResult>"
+		}
+		fmt.Fprintf(w, "<%[1]sResponse>%[2]s<ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></%[1]sResponse>", action, result)
+	}))
+	t.Cleanup(srv.Close)
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("ap-northeast-1").
+		WithEndpoint(srv.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	return sqs.New(sess), reqCh
+}
+
+func TestSentAt(t *testing.T) {
+	assert.Equal(t, time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC), sentAt(&sqs.Message{
+		Attributes: map[string]*string{"SentTimestamp": aws.String("1696161600000")},
+	}))
+	assert.True(t, sentAt(&sqs.Message{}).IsZero())
+}
+
+func TestGatewayReply(t *testing.T) {
+	ctx := context.Background()
+	queue, reqCh := newFakeSQS(t)
+
+	g := NewGateway(queue, "http://localhost/queue/source")
+	assert.NoError(t, g.reply(ctx, Message{ID: "id:1"}, []byte(`{}`), nil))
+	assert.Empty(t, reqCh, "nothing is sent without reply queue")
+
+	assert.NoError(t, g.reply(ctx, Message{
+		ID:         "id:1",
+		Attributes: map[string]string{"ResponseQueueUrl": "http://localhost/queue/reply"},
+	}, []byte(`{"result":1}`), nil))
+	req := <-reqCh
+	assert.Equal(t, "http://localhost/queue/reply", req.Get("QueueUrl"))
+	assert.Equal(t, `{"result":1}`, req.Get("MessageBody"))
+	assert.Equal(t, "", req.Get("MessageGroupId"))
+	attrs := map[string]string{}
+	for i := 1; req.Has(fmt.Sprintf("MessageAttribute.%d.Name", i)); i++ {
+		attrs[req.Get(fmt.Sprintf("MessageAttribute.%d.Name", i))] = req.Get(fmt.Sprintf("MessageAttribute.%d.Value.StringValue", i))
+	}
+	assert.Equal(t, map[string]string{"CorrelationId": "id:1", "Status": "success"}, attrs)
+
+	g = NewGateway(queue, "http://localhost/queue/source", FetcherReplyQueue("http://localhost/queue/reply.fifo"))
+	assert.NoError(t, g.reply(ctx, Message{ID: "id:2"}, nil, errors.New("failure response: 500")))
+	req = <-reqCh
+	assert.Equal(t, "http://localhost/queue/reply.fifo", req.Get("QueueUrl"))
+	assert.Equal(t, "{}", req.Get("MessageBody"))
+	assert.Equal(t, "id:2", req.Get("MessageGroupId"))
+	assert.Equal(t, "id:2", req.Get("MessageDeduplicationId"))
+	attrs = map[string]string{}
+	for i := 1; req.Has(fmt.Sprintf("MessageAttribute.%d.Name", i)); i++ {
+		attrs[req.Get(fmt.Sprintf("MessageAttribute.%d.Name", i))] = req.Get(fmt.Sprintf("MessageAttribute.%d.Value.StringValue", i))
+	}
+	assert.Equal(t, map[string]string{"CorrelationId": "id:2", "Status": "failure", "Error": "failure response: 500"}, attrs)
+
+	assert.NoError(t, g.reply(ctx, Message{ID: "id:3"}, nil, context.DeadlineExceeded))
+	req = <-reqCh
+	attrs = map[string]string{}
+	for i := 1; req.Has(fmt.Sprintf("MessageAttribute.%d.Name", i)); i++ {
+		attrs[req.Get(fmt.Sprintf("MessageAttribute.%d.Name", i))] = req.Get(fmt.Sprintf("MessageAttribute.%d.Value.StringValue", i))
+	}
+	assert.Equal(t, "timeout", attrs["Status"])
+}
+
+func TestGatewaySendFollowUps(t *testing.T) {
+	ctx := context.Background()
+	queue, reqCh := newFakeSQS(t)
+	g := NewGateway(queue, "http://localhost/queue/source")
+
+	assert.NoError(t, g.sendFollowUps(ctx, Message{ID: "id:1"}, nil))
+	assert.Empty(t, reqCh)
+
+	msgs := []FollowUpMessage{{QueueURL: "http://localhost/queue/next", Body: "0", Delay: time.Hour}}
+	for i := 1; i < 12; i++ {
+		msgs = append(msgs, FollowUpMessage{QueueURL: "http://localhost/queue/next", Body: strconv.Itoa(i)})
+	}
+	msgs = append(msgs, FollowUpMessage{
+		QueueURL:   "http://localhost/queue/next.fifo",
+		Body:       "fifo",
+		Attributes: map[string]string{"Step": "2"},
+		Delay:      time.Second,
+	})
+	assert.NoError(t, g.sendFollowUps(ctx, Message{ID: "id:1"}, msgs))
+
+	req := <-reqCh
+	assert.Equal(t, "SendMessageBatch", req.Get("Action"))
+	assert.Equal(t, "http://localhost/queue/next", req.Get("QueueUrl"))
+	assert.Equal(t, "0", req.Get("SendMessageBatchRequestEntry.1.MessageBody"))
+	assert.Equal(t, "900", req.Get("SendMessageBatchRequestEntry.1.DelaySeconds"), "delay is capped at 15 minutes")
+	assert.True(t, req.Has("SendMessageBatchRequestEntry.10.Id"))
+	assert.False(t, req.Has("SendMessageBatchRequestEntry.11.Id"))
+
+	req = <-reqCh
+	assert.Equal(t, "http://localhost/queue/next", req.Get("QueueUrl"))
+	assert.Equal(t, "10", req.Get("SendMessageBatchRequestEntry.1.MessageBody"))
+	assert.Equal(t, "11", req.Get("SendMessageBatchRequestEntry.2.MessageBody"))
+
+	req = <-reqCh
+	assert.Equal(t, "http://localhost/queue/next.fifo", req.Get("QueueUrl"))
+	assert.Equal(t, "fifo", req.Get("SendMessageBatchRequestEntry.1.MessageBody"))
+	assert.Equal(t, "id:1", req.Get("SendMessageBatchRequestEntry.1.MessageGroupId"))
+	assert.Equal(t, "id:1-12", req.Get("SendMessageBatchRequestEntry.1.MessageDeduplicationId"))
+	assert.Equal(t, "", req.Get("SendMessageBatchRequestEntry.1.DelaySeconds"))
+	assert.Equal(t, "Step", req.Get("SendMessageBatchRequestEntry.1.MessageAttribute.1.Name"))
+	assert.Empty(t, reqCh)
+}
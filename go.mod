@@ -1,13 +1,15 @@
 module github.com/taiyoh/sqsd
 
-go 1.21
+go 1.22
 
 require (
 	github.com/aws/aws-sdk-go v1.45.16
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.18.0
 	github.com/redis/rueidis v1.0.18
 	github.com/stretchr/testify v1.8.4
 	github.com/taiyoh/go-typedenv v0.1.1
+	golang.org/x/net v0.12.0
 	golang.org/x/sync v0.3.0
 	google.golang.org/grpc v1.58.2
 	google.golang.org/protobuf v1.31.0
@@ -19,7 +21,6 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/net v0.12.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
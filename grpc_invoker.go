@@ -0,0 +1,123 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCInvoker invokes worker process by Process RPC of Worker service.
+// Outcome of Result is mapped to the result of invocation:
+//
+//   - OUTCOME_DELETE: message is removed.
+//   - OUTCOME_RETAIN: ErrRetainMessage.
+//   - OUTCOME_RETRY_AFTER: RetryAfterError with delay.
+//   - OUTCOME_DEAD_LETTER: ErrDeadLetter.
+//
+// gRPC error status and other outcomes are treated as failure.
+type GRPCInvoker struct {
+	timeout  time.Duration
+	size     int
+	dialOpts []grpc.DialOption
+	conns    []*grpc.ClientConn
+	clients  []WorkerClient
+	next     atomic.Uint64
+}
+
+// GRPCInvokerOption is an option for GRPCInvoker.
+type GRPCInvokerOption func(*GRPCInvoker)
+
+// GRPCPoolSize sets count of connections to worker. requests are sent by round robin.
+// As default, 1 connection is used because gRPC multiplexes requests on a connection.
+func GRPCPoolSize(n int) GRPCInvokerOption {
+	return func(ivk *GRPCInvoker) {
+		ivk.size = n
+	}
+}
+
+// GRPCDialOptions adds options to dial worker, such as transport credentials.
+// As default, insecure credentials are used.
+func GRPCDialOptions(opts ...grpc.DialOption) GRPCInvokerOption {
+	return func(ivk *GRPCInvoker) {
+		ivk.dialOpts = append(ivk.dialOpts, opts...)
+	}
+}
+
+// NewGRPCInvoker returns GRPCInvoker instance. target is address of worker such as "localhost:50051".
+// Each request has deadline by dur.
+func NewGRPCInvoker(target string, dur time.Duration, opts ...GRPCInvokerOption) (*GRPCInvoker, error) {
+	if target == "" {
+		return nil, errors.New("target is required")
+	}
+	ivk := &GRPCInvoker{
+		timeout:  dur,
+		size:     1,
+		dialOpts: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	if ivk.size <= 0 {
+		return nil, errors.New("pool size must be greater than 0")
+	}
+	for i := 0; i < ivk.size; i++ {
+		conn, err := grpc.Dial(target, ivk.dialOpts...)
+		if err != nil {
+			_ = ivk.Close()
+			return nil, err
+		}
+		ivk.conns = append(ivk.conns, conn)
+		ivk.clients = append(ivk.clients, NewWorkerClient(conn))
+	}
+	return ivk, nil
+}
+
+// Invoke sends message as Job to worker.
+func (ivk *GRPCInvoker) Invoke(ctx context.Context, q Message) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	client := ivk.clients[(ivk.next.Add(1)-1)%uint64(len(ivk.clients))]
+	job := &Job{
+		Id:             q.ID,
+		Payload:        q.Payload,
+		Attributes:     q.Attributes,
+		IdempotencyKey: q.DedupKey,
+	}
+	if !q.ReceivedAt.IsZero() {
+		job.ReceivedAt = timestamppb.New(q.ReceivedAt)
+	}
+	res, err := client.Process(ctx, job)
+	if err != nil {
+		return err
+	}
+	if m := res.GetMessage(); m != "" {
+		getLogger().Info("worker result", "message_id", q.ID, "outcome", res.GetOutcome().String(), "message", m)
+	}
+	switch res.GetOutcome() {
+	case Outcome_OUTCOME_DELETE:
+		return nil
+	case Outcome_OUTCOME_RETAIN:
+		return ErrRetainMessage
+	case Outcome_OUTCOME_RETRY_AFTER:
+		return &RetryAfterError{Delay: res.GetDelay().AsDuration()}
+	case Outcome_OUTCOME_DEAD_LETTER:
+		return ErrDeadLetter
+	}
+	return fmt.Errorf("unknown outcome: %s", res.GetOutcome())
+}
+
+// Close closes all connections to worker.
+func (ivk *GRPCInvoker) Close() error {
+	var errs []error
+	for _, conn := range ivk.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
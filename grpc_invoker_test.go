@@ -0,0 +1,98 @@
+package sqsd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type testWorkerServer struct {
+	UnimplementedWorkerServer
+	jobs chan *Job
+}
+
+func (s *testWorkerServer) Process(ctx context.Context, job *Job) (*Result, error) {
+	s.jobs <- job
+	switch string(job.GetPayload()) {
+	case "delete":
+		return &Result{Outcome: Outcome_OUTCOME_DELETE}, nil
+	case "retain":
+		return &Result{Outcome: Outcome_OUTCOME_RETAIN, Message: "not yet"}, nil
+	case "retry":
+		return &Result{Outcome: Outcome_OUTCOME_RETRY_AFTER, Delay: durationpb.New(30 * time.Second)}, nil
+	case "dead":
+		return &Result{Outcome: Outcome_OUTCOME_DEAD_LETTER}, nil
+	case "sleep":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case "error":
+		return nil, status.Error(codes.Internal, "worker error")
+	}
+	return &Result{}, nil
+}
+
+func TestGRPCInvoker(t *testing.T) {
+	_, err := NewGRPCInvoker("", time.Second)
+	assert.EqualError(t, err, "target is required")
+	_, err = NewGRPCInvoker("localhost:50051", time.Second, GRPCPoolSize(0))
+	assert.EqualError(t, err, "pool size must be greater than 0")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &testWorkerServer{jobs: make(chan *Job, 10)}
+	server := grpc.NewServer()
+	RegisterWorkerServer(server, srv)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+
+	ivk, err := NewGRPCInvoker(l.Addr().String(), 300*time.Millisecond, GRPCPoolSize(2))
+	assert.NoError(t, err)
+	t.Cleanup(func() { ivk.Close() })
+
+	receivedAt := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		payload string
+		check   func(t *testing.T, err error)
+	}{
+		{payload: "delete", check: func(t *testing.T, err error) { assert.NoError(t, err) }},
+		{payload: "retain", check: func(t *testing.T, err error) { assert.ErrorIs(t, err, ErrRetainMessage) }},
+		{payload: "retry", check: func(t *testing.T, err error) {
+			var retryErr *RetryAfterError
+			assert.ErrorAs(t, err, &retryErr)
+			assert.Equal(t, 30*time.Second, retryErr.Delay)
+		}},
+		{payload: "dead", check: func(t *testing.T, err error) { assert.ErrorIs(t, err, ErrDeadLetter) }},
+		{payload: "sleep", check: func(t *testing.T, err error) {
+			assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		}},
+		{payload: "error", check: func(t *testing.T, err error) {
+			assert.Equal(t, codes.Internal, status.Code(err))
+		}},
+		{payload: "unknown", check: func(t *testing.T, err error) {
+			assert.EqualError(t, err, "unknown outcome: OUTCOME_UNSPECIFIED")
+		}},
+	} {
+		t.Run(tt.payload, func(t *testing.T) {
+			err := ivk.Invoke(context.Background(), Message{
+				ID:         "id:1",
+				Payload:    []byte(tt.payload),
+				DedupKey:   "key:1",
+				ReceivedAt: receivedAt,
+				Attributes: map[string]string{"type": "email"},
+			})
+			tt.check(t, err)
+			job := <-srv.jobs
+			assert.Equal(t, "id:1", job.GetId())
+			assert.Equal(t, "key:1", job.GetIdempotencyKey())
+			assert.Equal(t, map[string]string{"type": "email"}, job.GetAttributes())
+			assert.Equal(t, receivedAt, job.GetReceivedAt().AsTime())
+		})
+	}
+}
@@ -3,11 +3,22 @@ package sqsd
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/taiyoh/sqsd/signature"
 )
 
 // Invoker invokes worker process by any way.
@@ -15,51 +26,347 @@ type Invoker interface {
 	Invoke(context.Context, Message) error
 }
 
+// findInvoker returns the first invoker which implements T, from ivk and invokers wrapped by it.
+// Wrapped invokers are searched by Unwrap method in depth-first order, such as ShadowInvoker.Unwrap.
+func findInvoker[T any](ivk Invoker) (T, bool) {
+	if v, ok := ivk.(T); ok {
+		return v, true
+	}
+	if u, ok := ivk.(interface{ Unwrap() []Invoker }); ok {
+		for _, inner := range u.Unwrap() {
+			if v, ok := findInvoker[T](inner); ok {
+				return v, true
+			}
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// closeInvokers closes invokers which implement io.Closer.
+func closeInvokers(ivks ...Invoker) error {
+	var errs []error
+	for _, ivk := range ivks {
+		if c, ok := ivk.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // HTTPInvoker invokes worker process by HTTP POST request.
 type HTTPInvoker struct {
-	url string
-	cli *http.Client
+	url         string
+	cli         *http.Client
+	header      http.Header
+	secret      []byte
+	timeout     time.Duration
+	cloudEvents *cloudEvents
+}
+
+type httpInvokerParams struct {
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	h2c             bool
+	header          http.Header
+	tlsConfig       *tls.Config
+	secret          []byte
+	cloudEvents     *cloudEvents
+}
+
+// HTTPInvokerOption sets parameter to HTTPInvoker by functional option pattern.
+type HTTPInvokerOption func(*httpInvokerParams)
+
+// HTTPMaxIdleConns sets max count of keep-alive connections to worker. it is not used by h2c.
+// As default, values of http.DefaultTransport are used.
+func HTTPMaxIdleConns(n int) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.maxIdleConns = n
+	}
+}
+
+// HTTPIdleConnTimeout sets duration to keep idle connection to worker. it is not used by h2c.
+func HTTPIdleConnTimeout(d time.Duration) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.idleConnTimeout = d
+	}
+}
+
+// HTTPH2C enables HTTP/2 without TLS (h2c) with prior knowledge.
+// Worker must accept HTTP/2 connection without upgrade.
+func HTTPH2C() HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.h2c = true
+	}
+}
+
+// HTTPHeader adds static header to every request.
+// Headers set by sqsd such as X_AWS_SQSD_MSGID are not overwritten.
+func HTTPHeader(key, value string) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.header.Add(key, value)
+	}
+}
+
+// HTTPBasicAuth sets Authorization header by basic authentication.
+func HTTPBasicAuth(username, password string) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		cred := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		p.header.Set("Authorization", "Basic "+cred)
+	}
+}
+
+// HTTPBearerToken sets Authorization header by bearer token.
+func HTTPBearerToken(token string) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// HTTPTLSConfig sets TLS config of client, such as client certificates for mTLS and root CAs.
+func HTTPTLSConfig(cfg *tls.Config) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.tlsConfig = cfg
+	}
+}
+
+// HTTPSigningSecret signs every request by HMAC-SHA256 with secret.
+// see signature package for the scheme and verification.
+func HTTPSigningSecret(secret []byte) HTTPInvokerOption {
+	return func(p *httpInvokerParams) {
+		p.secret = secret
+	}
 }
 
 // NewHTTPInvoker returns HTTPInvoker instance.
-func NewHTTPInvoker(rawurl string, dur time.Duration) (*HTTPInvoker, error) {
-	if _, err := url.Parse(rawurl); err != nil {
+// rawurl can be Unix domain socket with request path, such as "unix:///run/worker.sock:/jobs".
+func NewHTTPInvoker(rawurl string, dur time.Duration, opts ...HTTPInvokerOption) (*HTTPInvoker, error) {
+	param := httpInvokerParams{
+		header: http.Header{},
+	}
+	for _, opt := range opts {
+		opt(&param)
+	}
+	reqURL, socket, err := parseInvokerURL(rawurl)
+	if err != nil {
 		return nil, err
 	}
+	if param.h2c && (reqURL.Scheme == "https" || param.tlsConfig != nil) {
+		return nil, errors.New("h2c can't be used with TLS")
+	}
 	return &HTTPInvoker{
-		url: rawurl,
+		url:    reqURL.String(),
+		header: param.header,
+		secret: param.secret,
 		cli: &http.Client{
-			Timeout: dur,
+			Transport: param.transport(socket),
 		},
+		timeout:     dur,
+		cloudEvents: param.cloudEvents,
 	}, nil
 }
 
+// parseInvokerURL returns URL to request and path of Unix domain socket if rawurl is "unix://" scheme.
+func parseInvokerURL(rawurl string) (*url.URL, string, error) {
+	rest, ok := strings.CutPrefix(rawurl, "unix://")
+	if !ok {
+		u, err := url.Parse(rawurl)
+		return u, "", err
+	}
+	socket, path, _ := strings.Cut(rest, ":")
+	if socket == "" {
+		return nil, "", errors.New("socket path is required")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	// host is not used to connect, but sent as Host header.
+	u, err := url.Parse("http://localhost" + path)
+	return u, socket, err
+}
+
+func (p *httpInvokerParams) transport(socket string) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := dialer.DialContext
+	if socket != "" {
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	if p.h2c {
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dial
+	t.TLSClientConfig = p.tlsConfig
+	if p.maxIdleConns > 0 {
+		t.MaxIdleConns = p.maxIdleConns
+		t.MaxIdleConnsPerHost = p.maxIdleConns
+	}
+	if p.idleConnTimeout > 0 {
+		t.IdleConnTimeout = p.idleConnTimeout
+	}
+	return t
+}
+
 // Invoke run http request to assigned URL.
+// Response body is sent to reply queue if it is configured.
+// Request has deadline of invocation in DeadlineHeader, which is the earlier of ctx's and timeout of invoker.
 func (ivk *HTTPInvoker) Invoke(ctx context.Context, q Message) error {
-	buf := bytes.NewBuffer([]byte(q.Payload))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ivk.url, buf)
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	body := q.Payload
+	header := invocationHeader(q)
+	if ivk.cloudEvents != nil {
+		b, h, err := ivk.cloudEvents.encode(q)
+		if err != nil {
+			return err
+		}
+		body = b
+		for k, vs := range h {
+			header[k] = vs
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ivk.url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("X_AWS_SQSD_MSGID", q.ID)
+	req.Header = header
+	setDeadlineHeader(ctx, req.Header)
+	for k, vs := range ivk.header {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = vs
+		}
+	}
+	if len(ivk.secret) > 0 {
+		signature.SignRequest(req, ivk.secret, time.Now(), body)
+	}
 	resp, err := ivk.cli.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if rec := responseRecorderFrom(ctx); rec != nil {
+		b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		if err != nil {
+			return err
+		}
+		SetResponse(ctx, b)
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			msgs, err := parseFollowUps(b)
+			if err != nil {
+				return err
+			}
+			AddFollowUp(ctx, msgs...)
+		}
+		return resultByStatus(resp.StatusCode, bytes.NewReader(b))
+	}
+	return resultByStatus(resp.StatusCode, resp.Body)
+}
+
+// batchItem is an item of request body of batch invocation.
+type batchItem struct {
+	MessageID         string            `json:"messageId"`
+	Body              string            `json:"body"`
+	MessageAttributes map[string]string `json:"messageAttributes,omitempty"`
+	IdempotencyKey    string            `json:"idempotencyKey,omitempty"`
+}
+
+// batchResponse is response body of batch invocation, which is the same as partial batch response of AWS Lambda.
+type batchResponse struct {
+	BatchItemFailures []struct {
+		ItemIdentifier string `json:"itemIdentifier"`
+	} `json:"batchItemFailures"`
+}
+
+// InvokeBatch sends messages as JSON array by a HTTP POST request.
+// Response body can report failed messages by {"batchItemFailures":[{"itemIdentifier":"<message id>"}]}.
+func (ivk *HTTPInvoker) InvokeBatch(ctx context.Context, msgs []Message) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	items := make([]batchItem, 0, len(msgs))
+	for _, q := range msgs {
+		payload, attrs := jsonPayload(q)
+		items = append(items, batchItem{
+			MessageID:         q.ID,
+			Body:              payload,
+			MessageAttributes: attrs,
+			IdempotencyKey:    q.DedupKey,
+		})
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ivk.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header = ivk.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X_AWS_SQSD_BATCH_SIZE", strconv.Itoa(len(msgs)))
+	setDeadlineHeader(ctx, req.Header)
+	if len(ivk.secret) > 0 {
+		signature.SignRequest(req, ivk.secret, time.Now(), body)
+	}
+	resp, err := ivk.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return resultByStatus(resp.StatusCode, resp.Body)
+	}
+	var br batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid batch response: %w", err)
+	}
+	if len(br.BatchItemFailures) == 0 {
+		return nil
+	}
+	failures := make(BatchItemFailures, 0, len(br.BatchItemFailures))
+	for _, f := range br.BatchItemFailures {
+		failures = append(failures, f.ItemIdentifier)
+	}
+	return failures
+}
+
+// invocationHeader returns headers which are sent to worker with message.
+func invocationHeader(q Message) http.Header {
+	h := http.Header{}
+	h.Add("Content-Type", contentType(q))
+	h.Add("X_AWS_SQSD_MSGID", q.ID)
+	if q.DedupKey != "" {
+		h.Add("X_AWS_SQSD_IDEMPOTENCY_KEY", q.DedupKey)
+	}
+	return h
+}
+
+// resultByStatus maps status code of worker response to result of invocation.
+// 5xx status is failure, and other status is success. 3xx and 4xx status are logged with body.
+func resultByStatus(status int, body io.Reader) error {
 	logger := getLogger()
-	switch s := resp.StatusCode; {
-	case s >= http.StatusInternalServerError:
-		b, _ := io.ReadAll(resp.Body)
+	switch {
+	case status >= http.StatusInternalServerError:
+		b, _ := io.ReadAll(body)
 		logger.Info("response is failure status",
-			"status_code", resp.StatusCode,
+			"status_code", status,
 			"body", string(b))
-		return fmt.Errorf("failure response: %d", s)
-	case s >= http.StatusMultipleChoices:
-		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failure response: %d", status)
+	case status >= http.StatusMultipleChoices:
+		b, _ := io.ReadAll(body)
 		logger.Info("response is not ok status",
-			"status_code", resp.StatusCode,
+			"status_code", status,
 			"body", string(b))
 	}
 	return nil
@@ -2,13 +2,26 @@ package sqsd
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/taiyoh/sqsd/signature"
 )
 
 type invokerTestPayload struct {
@@ -62,7 +75,7 @@ func TestHTTPInvoker(t *testing.T) {
 				Sleep:  time.Duration(tt.sleep) * time.Millisecond,
 			})
 			err := i.Invoke(context.Background(), Message{
-				Payload: string(b),
+				Payload: b,
 			})
 			if tt.expectedErr {
 				assert.Error(t, err)
@@ -72,3 +85,347 @@ func TestHTTPInvoker(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPInvokerHeaders(t *testing.T) {
+	headerCh := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerCh <- r.Header
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second)
+	assert.NoError(t, err)
+
+	assert.NoError(t, i.Invoke(context.Background(), Message{
+		ID:       "id:1",
+		Payload:  []byte(`{}`),
+		DedupKey: "order:1",
+	}))
+	h := <-headerCh
+	assert.Equal(t, "id:1", h.Get("X_AWS_SQSD_MSGID"))
+	assert.Equal(t, "order:1", h.Get("X_AWS_SQSD_IDEMPOTENCY_KEY"))
+}
+
+func TestHTTPInvokerDeadline(t *testing.T) {
+	headerCh := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerCh <- r.Header
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Second)
+		}
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Minute)
+	assert.NoError(t, err)
+
+	// deadline is the earlier of context's and timeout of invoker.
+	deadline := time.Now().Add(10 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	assert.NoError(t, i.Invoke(ctx, Message{ID: "id:1", Payload: []byte(`{}`)}))
+	sent, err := time.Parse(time.RFC3339Nano, (<-headerCh).Get(DeadlineHeader))
+	assert.NoError(t, err)
+	assert.True(t, deadline.Equal(sent))
+
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	sent, err = time.Parse(time.RFC3339Nano, (<-headerCh).Get(DeadlineHeader))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), sent, time.Second)
+
+	i, err = NewHTTPInvoker(srv.URL+"/slow", 100*time.Millisecond)
+	assert.NoError(t, err)
+	err = i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)})
+	<-headerCh
+	assert.True(t, isTimeout(err), err)
+}
+
+func TestParseInvokerURL(t *testing.T) {
+	for _, tt := range []struct {
+		rawurl  string
+		url     string
+		socket  string
+		wantErr string
+	}{
+		{rawurl: "http://localhost:8080/jobs", url: "http://localhost:8080/jobs"},
+		{rawurl: "unix:///run/worker.sock:/jobs", url: "http://localhost/jobs", socket: "/run/worker.sock"},
+		{rawurl: "unix:///run/worker.sock", url: "http://localhost/", socket: "/run/worker.sock"},
+		{rawurl: "unix://:/jobs", wantErr: "socket path is required"},
+	} {
+		u, socket, err := parseInvokerURL(tt.rawurl)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.url, u.String())
+		assert.Equal(t, tt.socket, socket)
+	}
+}
+
+func TestHTTPInvokerUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "worker.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+	pathCh := make(chan string, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathCh <- r.URL.Path
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker("unix://"+sock+":/jobs", time.Second, HTTPMaxIdleConns(4))
+	assert.NoError(t, err)
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	assert.Equal(t, "/jobs", <-pathCh)
+}
+
+func TestHTTPInvokerH2C(t *testing.T) {
+	protoCh := make(chan int, 1)
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protoCh <- r.ProtoMajor
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second, HTTPH2C())
+	assert.NoError(t, err)
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	assert.Equal(t, 2, <-protoCh)
+
+	_, err = NewHTTPInvoker("https://localhost", time.Second, HTTPH2C())
+	assert.EqualError(t, err, "h2c can't be used with TLS")
+}
+
+func TestHTTPInvokerStaticHeaders(t *testing.T) {
+	headerCh := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerCh <- r.Header
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		label         string
+		opt           HTTPInvokerOption
+		authorization string
+	}{
+		{label: "basic", opt: HTTPBasicAuth("user", "pass"), authorization: "Basic dXNlcjpwYXNz"},
+		{label: "bearer", opt: HTTPBearerToken("token"), authorization: "Bearer token"},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			i, err := NewHTTPInvoker(srv.URL, time.Second,
+				HTTPHeader("X-Tenant", "acme"),
+				HTTPHeader("X_AWS_SQSD_MSGID", "overwritten"),
+				tt.opt)
+			assert.NoError(t, err)
+			assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+			h := <-headerCh
+			assert.Equal(t, "acme", h.Get("X-Tenant"))
+			assert.Equal(t, "id:1", h.Get("X_AWS_SQSD_MSGID"))
+			assert.Equal(t, tt.authorization, h.Get("Authorization"))
+		})
+	}
+}
+
+func TestHTTPInvokerMutualTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sqsd"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	cnCh := make(chan string, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnCh <- r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	i, err := NewHTTPInvoker(srv.URL, time.Second, HTTPTLSConfig(&tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}))
+	assert.NoError(t, err)
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{}`)}))
+	assert.Equal(t, "sqsd", <-cnCh)
+}
+
+func TestHTTPInvokerSigning(t *testing.T) {
+	verifier := signature.NewVerifier([]byte("secret"))
+	errCh := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := verifier.Verify(r)
+		errCh <- err
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second, HTTPSigningSecret([]byte("secret")))
+	assert.NoError(t, err)
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{"hello":"world"}`)}))
+	assert.NoError(t, <-errCh)
+
+	i, err = NewHTTPInvoker(srv.URL, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "id:1", Payload: []byte(`{"hello":"world"}`)}))
+	assert.ErrorIs(t, <-errCh, signature.ErrMissingHeader)
+}
+
+func TestHTTPInvokerBatch(t *testing.T) {
+	type request struct {
+		header http.Header
+		items  []batchItem
+	}
+	requestCh := make(chan request, 1)
+	responses := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var items []batchItem
+		_ = json.NewDecoder(r.Body).Decode(&items)
+		requestCh <- request{header: r.Header, items: items}
+		resp := <-responses
+		if resp == "500" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second, HTTPHeader("X-Tenant", "acme"))
+	assert.NoError(t, err)
+	msgs := []Message{
+		{ID: "id:1", Payload: []byte(`{"n":1}`), Attributes: map[string]string{"type": "email"}},
+		{ID: "id:2", Payload: []byte(`{"n":2}`), DedupKey: "key:2"},
+	}
+
+	for _, tt := range []struct {
+		label    string
+		response string
+		check    func(t *testing.T, err error)
+	}{
+		{label: "empty body", response: "", check: func(t *testing.T, err error) { assert.NoError(t, err) }},
+		{label: "no failures", response: `{"batchItemFailures":[]}`, check: func(t *testing.T, err error) { assert.NoError(t, err) }},
+		{label: "partial failure", response: `{"batchItemFailures":[{"itemIdentifier":"id:2"}]}`, check: func(t *testing.T, err error) {
+			var failures BatchItemFailures
+			assert.ErrorAs(t, err, &failures)
+			assert.Equal(t, BatchItemFailures{"id:2"}, failures)
+		}},
+		{label: "invalid body", response: `[`, check: func(t *testing.T, err error) {
+			assert.ErrorContains(t, err, "invalid batch response")
+		}},
+		{label: "500", response: "500", check: func(t *testing.T, err error) {
+			assert.EqualError(t, err, "failure response: 500")
+		}},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			responses <- tt.response
+			err := i.InvokeBatch(context.Background(), msgs)
+			tt.check(t, err)
+			req := <-requestCh
+			assert.Equal(t, "2", req.header.Get("X_AWS_SQSD_BATCH_SIZE"))
+			assert.Equal(t, "acme", req.header.Get("X-Tenant"))
+			assert.Equal(t, []batchItem{
+				{MessageID: "id:1", Body: `{"n":1}`, MessageAttributes: map[string]string{"type": "email"}},
+				{MessageID: "id:2", Body: `{"n":2}`, IdempotencyKey: "key:2"},
+			}, req.items)
+		})
+	}
+}
+
+func TestHTTPInvokerResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X_AWS_SQSD_MSGID") == "failure" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(`{"id":"` + r.Header.Get("X_AWS_SQSD_MSGID") + `"}`))
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second)
+	assert.NoError(t, err)
+
+	ctx, rec := withResponseRecorder(context.Background())
+	assert.NoError(t, i.Invoke(ctx, Message{ID: "ok", Payload: []byte(`{}`)}))
+	assert.Equal(t, `{"id":"ok"}`, string(rec.Body()))
+
+	ctx, rec = withResponseRecorder(context.Background())
+	assert.Error(t, i.Invoke(ctx, Message{ID: "failure", Payload: []byte(`{}`)}))
+	assert.Equal(t, `{"id":"failure"}`, string(rec.Body()))
+
+	// nothing is recorded without recorder.
+	assert.NoError(t, i.Invoke(context.Background(), Message{ID: "ok", Payload: []byte(`{}`)}))
+}
+
+func TestHTTPInvokerFollowUps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X_AWS_SQSD_MSGID") {
+		case "invalid":
+			_, _ = w.Write([]byte(`{"followUps":[{"body":"no queue"}]}`))
+		case "failure":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"followUps":[{"queueUrl":"http://localhost/queue/next","body":"ignored"}]}`))
+		case "plain":
+			_, _ = w.Write([]byte(`ok`))
+		default:
+			_, _ = w.Write([]byte(`{"followUps":[{"queueUrl":"http://localhost/queue/next","body":"step2","attributes":{"Step":"2"},"delaySeconds":30}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	i, err := NewHTTPInvoker(srv.URL, time.Second)
+	assert.NoError(t, err)
+
+	ctx, rec := withResponseRecorder(context.Background())
+	assert.NoError(t, i.Invoke(ctx, Message{ID: "ok", Payload: []byte(`{}`)}))
+	assert.Equal(t, []FollowUpMessage{{
+		QueueURL:   "http://localhost/queue/next",
+		Body:       "step2",
+		Attributes: map[string]string{"Step": "2"},
+		Delay:      30 * time.Second,
+	}}, rec.FollowUps())
+
+	ctx, rec = withResponseRecorder(context.Background())
+	assert.NoError(t, i.Invoke(ctx, Message{ID: "plain", Payload: []byte(`{}`)}))
+	assert.Empty(t, rec.FollowUps())
+
+	ctx, rec = withResponseRecorder(context.Background())
+	assert.Error(t, i.Invoke(ctx, Message{ID: "failure", Payload: []byte(`{}`)}))
+	assert.Empty(t, rec.FollowUps())
+
+	ctx, rec = withResponseRecorder(context.Background())
+	assert.EqualError(t, i.Invoke(ctx, Message{ID: "invalid", Payload: []byte(`{}`)}), "invalid follow-up messages: queueUrl is required at 0")
+	assert.Empty(t, rec.FollowUps())
+}
+
+func TestFindInvoker(t *testing.T) {
+	ok := InvokerFunc(func(ctx context.Context, q Message) error { return nil })
+	balanced, err := NewBalancedInvoker([]Endpoint{{URL: "http://127.0.0.1:1/jobs"}}, time.Second)
+	assert.NoError(t, err)
+	defer balanced.Close()
+	shadow, err := NewShadowInvoker(balanced, ok)
+	assert.NoError(t, err)
+	canary, err := NewCanaryInvoker(shadow, ok, 0)
+	assert.NoError(t, err)
+	router, err := NewRoutingInvoker([]Route{
+		{Name: "report", Match: MatchAttribute("Type", "report"), Invoker: ok},
+	}, RouterDefault(canary, 1))
+	assert.NoError(t, err)
+
+	r, found := findInvoker[EndpointReporter](router)
+	assert.True(t, found, "balanced invoker is found through routing, canary and shadow invokers")
+	assert.Equal(t, balanced, r)
+	c, found := findInvoker[CanaryController](router)
+	assert.True(t, found)
+	assert.Equal(t, canary, c)
+
+	_, found = findInvoker[CanaryController](shadow)
+	assert.False(t, found)
+}
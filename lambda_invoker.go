@@ -0,0 +1,125 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// LambdaInvoker invokes AWS Lambda function synchronously by Invoke API with SQS event.
+// FunctionError of response means failure, and message reported in batchItemFailures of response is also treated as failure.
+// It implements BatchInvoker, so that multiple messages are passed to function as records of one event.
+type LambdaInvoker struct {
+	client         lambdaiface.LambdaAPI
+	function       string
+	qualifier      string
+	eventSourceARN string
+	timeout        time.Duration
+}
+
+var _ BatchInvoker = (*LambdaInvoker)(nil)
+
+// LambdaInvokerOption is an option for LambdaInvoker.
+type LambdaInvokerOption func(*LambdaInvoker)
+
+// LambdaInvokeQualifier sets version or alias of function to invoke.
+func LambdaInvokeQualifier(qualifier string) LambdaInvokerOption {
+	return func(ivk *LambdaInvoker) {
+		ivk.qualifier = qualifier
+	}
+}
+
+// LambdaInvokeEventSourceARN sets eventSourceARN of SQS event record, which is ARN of queue.
+func LambdaInvokeEventSourceARN(queueARN string) LambdaInvokerOption {
+	return func(ivk *LambdaInvoker) {
+		ivk.eventSourceARN = queueARN
+	}
+}
+
+// NewLambdaInvoker returns LambdaInvoker instance. function is name or ARN of function.
+// Endpoint of Lambda, such as local stand-in, is configured by client.
+// Each invocation has deadline by dur.
+func NewLambdaInvoker(client lambdaiface.LambdaAPI, function string, dur time.Duration, opts ...LambdaInvokerOption) (*LambdaInvoker, error) {
+	if client == nil {
+		return nil, errors.New("lambda client is required")
+	}
+	if function == "" {
+		return nil, errors.New("function is required")
+	}
+	ivk := &LambdaInvoker{
+		client:   client,
+		function: function,
+		timeout:  dur,
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	return ivk, nil
+}
+
+// Invoke invokes function with SQS event which has message as record.
+func (ivk *LambdaInvoker) Invoke(ctx context.Context, q Message) error {
+	payload, err := ivk.invoke(ctx, []Message{q})
+	if err != nil {
+		return err
+	}
+	SetResponse(ctx, payload)
+	return batchItemFailure(payload, q.ID)
+}
+
+// InvokeBatch invokes function with SQS event which has messages as records.
+func (ivk *LambdaInvoker) InvokeBatch(ctx context.Context, msgs []Message) error {
+	payload, err := ivk.invoke(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	var res batchResponse
+	if err := json.Unmarshal(payload, &res); err != nil || len(res.BatchItemFailures) == 0 {
+		// response which is not partial batch response means that all messages succeed.
+		return nil
+	}
+	failures := make(BatchItemFailures, 0, len(res.BatchItemFailures))
+	for _, f := range res.BatchItemFailures {
+		failures = append(failures, f.ItemIdentifier)
+	}
+	return failures
+}
+
+func (ivk *LambdaInvoker) invoke(ctx context.Context, msgs []Message) ([]byte, error) {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	event, err := sqsEvent(msgs, ivk.eventSourceARN)
+	if err != nil {
+		return nil, err
+	}
+	input := &lambda.InvokeInput{
+		FunctionName:   aws.String(ivk.function),
+		InvocationType: aws.String(lambda.InvocationTypeRequestResponse),
+		Payload:        event,
+	}
+	if ivk.qualifier != "" {
+		input.Qualifier = aws.String(ivk.qualifier)
+	}
+	out, err := ivk.client.InvokeWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if fe := aws.StringValue(out.FunctionError); fe != "" {
+		e := &LambdaFunctionError{}
+		if err := json.Unmarshal(out.Payload, e); err != nil || e.Type == "" {
+			e.Type = fe
+		}
+		return nil, e
+	}
+	if status := aws.Int64Value(out.StatusCode); status < 200 || status >= 300 {
+		return nil, fmt.Errorf("failure response: %d", status)
+	}
+	return out.Payload, nil
+}
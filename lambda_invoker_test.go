@@ -0,0 +1,109 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLambdaRequest struct {
+	path      string
+	qualifier string
+	event     testLambdaEvent
+}
+
+// newFakeLambda returns Lambda client whose Invoke requests are handled by handler.
+// handler returns function error (empty for success) and payload of response.
+func newFakeLambda(t *testing.T, handler func(testLambdaEvent) (string, string)) (*lambda.Lambda, chan testLambdaRequest) {
+	reqCh := make(chan testLambdaRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var ev testLambdaEvent
+		_ = json.Unmarshal(b, &ev)
+		reqCh <- testLambdaRequest{path: r.URL.Path, qualifier: r.URL.Query().Get("Qualifier"), event: ev}
+		fe, payload := handler(ev)
+		if fe != "" {
+			w.Header().Set("X-Amz-Function-Error", fe)
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	t.Cleanup(srv.Close)
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("ap-northeast-1").
+		WithEndpoint(srv.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))))
+	return lambda.New(sess), reqCh
+}
+
+func TestLambdaInvoker(t *testing.T) {
+	client, reqCh := newFakeLambda(t, func(ev testLambdaEvent) (string, string) {
+		switch ev.Records[0].Body {
+		case "unhandled":
+			return "Unhandled", `{"errorType":"TypeError","errorMessage":"boom"}`
+		case "handled":
+			return "Handled", `"not object"`
+		case "partial":
+			return "", `{"batchItemFailures":[{"itemIdentifier":"` + ev.Records[0].MessageID + `"}]}`
+		}
+		return "", `{"ok":true}`
+	})
+	ivk, err := NewLambdaInvoker(client, "my-function", time.Second,
+		LambdaInvokeQualifier("live"),
+		LambdaInvokeEventSourceARN("arn:aws:sqs:ap-northeast-1:123456789012:MyQueue"))
+	assert.NoError(t, err)
+
+	ctx, rec := withResponseRecorder(context.Background())
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:1", Payload: []byte("hello")}))
+	assert.Equal(t, `{"ok":true}`, string(rec.Body()))
+	req := <-reqCh
+	assert.Equal(t, "/2015-03-31/functions/my-function/invocations", req.path)
+	assert.Equal(t, "live", req.qualifier)
+	assert.Len(t, req.event.Records, 1)
+	assert.Equal(t, "id:1", req.event.Records[0].MessageID)
+	assert.Equal(t, "arn:aws:sqs:ap-northeast-1:123456789012:MyQueue", req.event.Records[0].EventSourceARN)
+
+	err = ivk.Invoke(context.Background(), Message{ID: "id:2", Payload: []byte("unhandled")})
+	assert.EqualError(t, err, "lambda function error: TypeError: boom")
+	<-reqCh
+	err = ivk.Invoke(context.Background(), Message{ID: "id:3", Payload: []byte("handled")})
+	var fnErr *LambdaFunctionError
+	assert.ErrorAs(t, err, &fnErr)
+	assert.Equal(t, "Handled", fnErr.Type)
+	<-reqCh
+	assert.EqualError(t, ivk.Invoke(context.Background(), Message{ID: "id:4", Payload: []byte("partial")}), "message is reported in batchItemFailures")
+	<-reqCh
+}
+
+func TestLambdaInvokerBatch(t *testing.T) {
+	client, reqCh := newFakeLambda(t, func(ev testLambdaEvent) (string, string) {
+		if ev.Records[0].Body == "unhandled" {
+			return "Unhandled", `{"errorType":"Error","errorMessage":"boom"}`
+		}
+		return "", `{"batchItemFailures":[{"itemIdentifier":"id:2"}]}`
+	})
+	ivk, err := NewLambdaInvoker(client, "arn:aws:lambda:ap-northeast-1:123456789012:function:my-function", time.Second)
+	assert.NoError(t, err)
+
+	err = ivk.InvokeBatch(context.Background(), []Message{{ID: "id:1"}, {ID: "id:2"}, {ID: "id:3"}})
+	assert.Equal(t, BatchItemFailures{"id:2"}, err)
+	req := <-reqCh
+	assert.Len(t, req.event.Records, 3)
+	assert.Equal(t, "", req.qualifier)
+
+	err = ivk.InvokeBatch(context.Background(), []Message{{ID: "id:1", Payload: []byte("unhandled")}})
+	var fnErr *LambdaFunctionError
+	assert.ErrorAs(t, err, &fnErr)
+
+	_, err = NewLambdaInvoker(client, "", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,374 @@
+package sqsd
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// QueueARN returns ARN of SQS queue from its URL such as "https://sqs.ap-northeast-1.amazonaws.com/123456789012/MyQueue".
+// region is used when it can't be taken from host of URL, such as local endpoint.
+func QueueARN(queueURL, region string) (string, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return "", err
+	}
+	account, name, ok := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if !ok || account == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid queue url: %s", queueURL)
+	}
+	host := u.Hostname()
+	switch {
+	case strings.HasPrefix(host, "sqs.") && strings.HasSuffix(host, ".amazonaws.com"):
+		region = strings.TrimSuffix(strings.TrimPrefix(host, "sqs."), ".amazonaws.com")
+	case strings.HasSuffix(host, ".queue.amazonaws.com"):
+		region = strings.TrimSuffix(host, ".queue.amazonaws.com")
+	case host == "queue.amazonaws.com":
+		region = "us-east-1"
+	}
+	return arn.ARN{
+		Partition: "aws",
+		Service:   "sqs",
+		Region:    region,
+		AccountID: account,
+		Resource:  name,
+	}.String(), nil
+}
+
+// lambdaRuntimePrefix is the path prefix of Lambda Runtime API.
+const lambdaRuntimePrefix = "/2018-06-01/runtime/"
+
+type sqsEventRecord struct {
+	MessageID         string                            `json:"messageId"`
+	ReceiptHandle     string                            `json:"receiptHandle"`
+	Body              string                            `json:"body"`
+	Attributes        map[string]string                 `json:"attributes"`
+	MessageAttributes map[string]sqsEventAttributeValue `json:"messageAttributes"`
+	MD5OfBody         string                            `json:"md5OfBody"`
+	EventSource       string                            `json:"eventSource"`
+	EventSourceARN    string                            `json:"eventSourceARN"`
+	AWSRegion         string                            `json:"awsRegion"`
+}
+
+type sqsEventAttributeValue struct {
+	StringValue      string   `json:"stringValue"`
+	StringListValues []string `json:"stringListValues"`
+	BinaryListValues []string `json:"binaryListValues"`
+	DataType         string   `json:"dataType"`
+}
+
+type lambdaResult struct {
+	body []byte
+	err  error
+}
+
+type lambdaInvocation struct {
+	requestID string
+	event     []byte
+	deadline  time.Time
+	messageID string
+	result    chan lambdaResult
+}
+
+// LambdaRuntimeInvoker serves AWS Lambda Runtime API, so that Lambda runtime (bootstrap) works as worker process.
+// Each message is passed to runtime as SQS event which has one record.
+// Response of runtime means success, and error of runtime or timeout means failure.
+// Response which reports the message in batchItemFailures is also treated as failure.
+type LambdaRuntimeInvoker struct {
+	timeout        time.Duration
+	eventSourceARN string
+	functionARN    string
+
+	queue chan *lambdaInvocation
+
+	mu       sync.Mutex
+	inflight map[string]*lambdaInvocation
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// LambdaRuntimeOption is an option for LambdaRuntimeInvoker.
+type LambdaRuntimeOption func(*LambdaRuntimeInvoker)
+
+// LambdaEventSourceARN sets eventSourceARN of SQS event record, which is ARN of queue. region of record is taken from it.
+func LambdaEventSourceARN(queueARN string) LambdaRuntimeOption {
+	return func(ivk *LambdaRuntimeInvoker) {
+		ivk.eventSourceARN = queueARN
+	}
+}
+
+// LambdaFunctionARN sets Lambda-Runtime-Invoked-Function-Arn header.
+// As default, "arn:aws:lambda:local:000000000000:function:sqsd" is used.
+func LambdaFunctionARN(functionARN string) LambdaRuntimeOption {
+	return func(ivk *LambdaRuntimeInvoker) {
+		ivk.functionARN = functionARN
+	}
+}
+
+// NewLambdaRuntimeInvoker returns LambdaRuntimeInvoker instance which serves Runtime API on addr such as "127.0.0.1:9001".
+// Runtime should be started with AWS_LAMBDA_RUNTIME_API environment variable set to the address.
+// Empty addr doesn't listen, and LambdaRuntimeInvoker can be used as http.Handler.
+// Each invocation has deadline by dur.
+func NewLambdaRuntimeInvoker(addr string, dur time.Duration, opts ...LambdaRuntimeOption) (*LambdaRuntimeInvoker, error) {
+	ivk := &LambdaRuntimeInvoker{
+		timeout:     dur,
+		functionARN: "arn:aws:lambda:local:000000000000:function:sqsd",
+		queue:       make(chan *lambdaInvocation),
+		inflight:    map[string]*lambdaInvocation{},
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	if addr == "" {
+		return ivk, nil
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	ivk.listener = l
+	// no timeouts, because runtime waits next invocation by long polling.
+	ivk.server = &http.Server{Handler: ivk, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := ivk.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			getLogger().Error("lambda runtime api server stops", "error", err)
+		}
+	}()
+	return ivk, nil
+}
+
+// Addr returns address on which Runtime API is served.
+func (ivk *LambdaRuntimeInvoker) Addr() string {
+	if ivk.listener == nil {
+		return ""
+	}
+	return ivk.listener.Addr().String()
+}
+
+// Close stops Runtime API server.
+func (ivk *LambdaRuntimeInvoker) Close() error {
+	if ivk.server == nil {
+		return nil
+	}
+	return ivk.server.Close()
+}
+
+func newLambdaRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// sqsEvent returns SQS event of AWS Lambda which has messages as records.
+func sqsEvent(msgs []Message, eventSourceARN string) ([]byte, error) {
+	var region string
+	if a, err := arn.Parse(eventSourceARN); err == nil {
+		region = a.Region
+	}
+	records := make([]sqsEventRecord, 0, len(msgs))
+	for _, q := range msgs {
+		body, attrs := jsonPayload(q)
+		sum := md5.Sum([]byte(body))
+		record := sqsEventRecord{
+			MessageID:         q.ID,
+			ReceiptHandle:     q.Receipt,
+			Body:              body,
+			Attributes:        map[string]string{},
+			MessageAttributes: make(map[string]sqsEventAttributeValue, len(attrs)),
+			MD5OfBody:         hex.EncodeToString(sum[:]),
+			EventSource:       "aws:sqs",
+			EventSourceARN:    eventSourceARN,
+			AWSRegion:         region,
+		}
+		if !q.ReceivedAt.IsZero() {
+			record.Attributes["ApproximateFirstReceiveTimestamp"] = strconv.FormatInt(q.ReceivedAt.UnixMilli(), 10)
+		}
+		if !q.SentAt.IsZero() {
+			record.Attributes["SentTimestamp"] = strconv.FormatInt(q.SentAt.UnixMilli(), 10)
+		}
+		for k, v := range attrs {
+			record.MessageAttributes[k] = sqsEventAttributeValue{
+				StringValue:      v,
+				StringListValues: []string{},
+				BinaryListValues: []string{},
+				DataType:         "String",
+			}
+		}
+		records = append(records, record)
+	}
+	return json.Marshal(struct {
+		Records []sqsEventRecord `json:"Records"`
+	}{Records: records})
+}
+
+// Invoke passes message to runtime which waits next invocation, and waits its response or error.
+func (ivk *LambdaRuntimeInvoker) Invoke(ctx context.Context, q Message) error {
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	event, err := sqsEvent([]Message{q}, ivk.eventSourceARN)
+	if err != nil {
+		return err
+	}
+	inv := &lambdaInvocation{
+		requestID: newLambdaRequestID(),
+		event:     event,
+		messageID: q.ID,
+		result:    make(chan lambdaResult, 1),
+	}
+	inv.deadline, _ = ctx.Deadline()
+
+	ivk.mu.Lock()
+	ivk.inflight[inv.requestID] = inv
+	ivk.mu.Unlock()
+	defer func() {
+		ivk.mu.Lock()
+		delete(ivk.inflight, inv.requestID)
+		ivk.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("no lambda runtime takes invocation: %w", ctx.Err())
+	case ivk.queue <- inv:
+	}
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("lambda runtime doesn't respond: %w", ctx.Err())
+	case res := <-inv.result:
+		if res.err != nil {
+			return res.err
+		}
+		SetResponse(ctx, res.body)
+		return batchItemFailure(res.body, q.ID)
+	}
+}
+
+// batchItemFailure returns error if response body reports message as batch item failure.
+func batchItemFailure(body []byte, messageID string) error {
+	var res batchResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil
+	}
+	for _, f := range res.BatchItemFailures {
+		if f.ItemIdentifier == messageID {
+			return errors.New("message is reported in batchItemFailures")
+		}
+	}
+	return nil
+}
+
+// LambdaFunctionError is returned when runtime reports error of invocation.
+type LambdaFunctionError struct {
+	Type    string `json:"errorType"`
+	Message string `json:"errorMessage"`
+}
+
+// Error implements error interface.
+func (e *LambdaFunctionError) Error() string {
+	return fmt.Sprintf("lambda function error: %s: %s", e.Type, e.Message)
+}
+
+func writeLambdaError(w http.ResponseWriter, status int, typ, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&LambdaFunctionError{Type: typ, Message: msg})
+}
+
+// ServeHTTP handles Runtime API requests.
+func (ivk *LambdaRuntimeInvoker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, lambdaRuntimePrefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case path == "invocation/next" && r.Method == http.MethodGet:
+		ivk.next(w, r)
+	case path == "init/error" && r.Method == http.MethodPost:
+		var e LambdaFunctionError
+		_ = json.NewDecoder(io.LimitReader(r.Body, maxResponseSize)).Decode(&e)
+		getLogger().Error("lambda runtime fails to initialize", "error_type", e.Type, "error_message", e.Message)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(path, "invocation/") && r.Method == http.MethodPost:
+		requestID, kind, _ := strings.Cut(strings.TrimPrefix(path, "invocation/"), "/")
+		switch kind {
+		case "response", "error":
+			ivk.complete(w, r, requestID, kind == "error")
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (ivk *LambdaRuntimeInvoker) next(w http.ResponseWriter, r *http.Request) {
+	var inv *lambdaInvocation
+	select {
+	case <-r.Context().Done():
+		return
+	case inv = <-ivk.queue:
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Lambda-Runtime-Aws-Request-Id", inv.requestID)
+	h.Set("Lambda-Runtime-Invoked-Function-Arn", ivk.functionARN)
+	if !inv.deadline.IsZero() {
+		h.Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(inv.deadline.UnixMilli(), 10))
+	}
+	getLogger().Debug("invocation is passed to lambda runtime", "message_id", inv.messageID, "request_id", inv.requestID)
+	_, _ = w.Write(inv.event)
+}
+
+func (ivk *LambdaRuntimeInvoker) complete(w http.ResponseWriter, r *http.Request, requestID string, failed bool) {
+	ivk.mu.Lock()
+	inv, ok := ivk.inflight[requestID]
+	ivk.mu.Unlock()
+	if !ok {
+		writeLambdaError(w, http.StatusBadRequest, "InvalidRequestID", "unknown or expired request id: "+requestID)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxResponseSize+1))
+	if err != nil {
+		writeLambdaError(w, http.StatusInternalServerError, "ReadError", err.Error())
+		return
+	}
+	if len(body) > maxResponseSize {
+		writeLambdaError(w, http.StatusRequestEntityTooLarge, "RequestEntityTooLarge", "response is too large")
+		return
+	}
+	res := lambdaResult{body: body}
+	if failed {
+		e := &LambdaFunctionError{}
+		if err := json.Unmarshal(body, e); err != nil || e.Type == "" {
+			e.Type = r.Header.Get("Lambda-Runtime-Function-Error-Type")
+		}
+		res.err = e
+	}
+	select {
+	case inv.result <- res:
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"OK"}`))
+	default:
+		writeLambdaError(w, http.StatusBadRequest, "InvalidStateTransition", "result of invocation is already reported: "+requestID)
+	}
+}
@@ -0,0 +1,163 @@
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueARN(t *testing.T) {
+	for _, tt := range []struct {
+		url, region, expected string
+	}{
+		{"https://sqs.ap-northeast-1.amazonaws.com/123456789012/MyQueue", "us-west-2", "arn:aws:sqs:ap-northeast-1:123456789012:MyQueue"},
+		{"https://eu-west-1.queue.amazonaws.com/123456789012/MyQueue.fifo", "", "arn:aws:sqs:eu-west-1:123456789012:MyQueue.fifo"},
+		{"https://queue.amazonaws.com/123456789012/MyQueue", "", "arn:aws:sqs:us-east-1:123456789012:MyQueue"},
+		{"http://localhost:4566/000000000000/local", "ap-northeast-1", "arn:aws:sqs:ap-northeast-1:000000000000:local"},
+	} {
+		a, err := QueueARN(tt.url, tt.region)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, a)
+	}
+	_, err := QueueARN("http://localhost:4566/queue", "")
+	assert.Error(t, err)
+}
+
+type testLambdaEvent struct {
+	Records []sqsEventRecord
+}
+
+// runTestLambdaRuntime behaves as Lambda runtime until ctx is done. handler returns response body, or error to report.
+func runTestLambdaRuntime(ctx context.Context, api string, handler func(testLambdaEvent, http.Header) ([]byte, error)) {
+	for ctx.Err() == nil {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+api+"/2018-06-01/runtime/invocation/next", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			continue
+		}
+		var ev testLambdaEvent
+		_ = json.NewDecoder(resp.Body).Decode(&ev)
+		resp.Body.Close()
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		body, err := handler(ev, resp.Header)
+		path := "/response"
+		if err != nil {
+			path = "/error"
+			body, _ = json.Marshal(map[string]string{"errorType": "Error", "errorMessage": err.Error()})
+		}
+		resp, err = http.Post("http://"+api+"/2018-06-01/runtime/invocation/"+requestID+path, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestLambdaRuntimeInvoker(t *testing.T) {
+	ivk, err := NewLambdaRuntimeInvoker("127.0.0.1:0", time.Second,
+		LambdaEventSourceARN("arn:aws:sqs:ap-northeast-1:123456789012:MyQueue"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = ivk.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	headers := make(chan http.Header, 10)
+	records := make(chan sqsEventRecord, 10)
+	go runTestLambdaRuntime(ctx, ivk.Addr(), func(ev testLambdaEvent, h http.Header) ([]byte, error) {
+		headers <- h
+		r := ev.Records[0]
+		records <- r
+		switch r.Body {
+		case "error":
+			return nil, errors.New("handler failed")
+		case "partial":
+			return []byte(`{"batchItemFailures":[{"itemIdentifier":"` + r.MessageID + `"}]}`), nil
+		}
+		return []byte(`{"ok":true}`), nil
+	})
+
+	receivedAt := time.UnixMilli(1696161600000)
+	rctx, rec := withResponseRecorder(context.Background())
+	assert.NoError(t, ivk.Invoke(rctx, Message{
+		ID:         "id:1",
+		Payload:    []byte("hello"),
+		Receipt:    "receipt",
+		ReceivedAt: receivedAt,
+		Attributes: map[string]string{"Type": "email"},
+	}))
+	assert.Equal(t, `{"ok":true}`, string(rec.Body()))
+	h := <-headers
+	assert.NotEmpty(t, h.Get("Lambda-Runtime-Aws-Request-Id"))
+	assert.NotEmpty(t, h.Get("Lambda-Runtime-Deadline-Ms"))
+	assert.Equal(t, "arn:aws:lambda:local:000000000000:function:sqsd", h.Get("Lambda-Runtime-Invoked-Function-Arn"))
+	assert.Equal(t, sqsEventRecord{
+		MessageID:     "id:1",
+		ReceiptHandle: "receipt",
+		Body:          "hello",
+		Attributes:    map[string]string{"ApproximateFirstReceiveTimestamp": "1696161600000"},
+		MessageAttributes: map[string]sqsEventAttributeValue{
+			"Type": {StringValue: "email", StringListValues: []string{}, BinaryListValues: []string{}, DataType: "String"},
+		},
+		MD5OfBody:      "5d41402abc4b2a76b9719d911017c592",
+		EventSource:    "aws:sqs",
+		EventSourceARN: "arn:aws:sqs:ap-northeast-1:123456789012:MyQueue",
+		AWSRegion:      "ap-northeast-1",
+	}, <-records)
+
+	err = ivk.Invoke(context.Background(), Message{ID: "id:2", Payload: []byte("error")})
+	var fnErr *LambdaFunctionError
+	assert.ErrorAs(t, err, &fnErr)
+	assert.Equal(t, "Error", fnErr.Type)
+	assert.Equal(t, "handler failed", fnErr.Message)
+
+	assert.EqualError(t, ivk.Invoke(context.Background(), Message{ID: "id:3", Payload: []byte("partial")}), "message is reported in batchItemFailures")
+}
+
+func TestLambdaRuntimeInvokerTimeout(t *testing.T) {
+	ivk, err := NewLambdaRuntimeInvoker("127.0.0.1:0", 100*time.Millisecond)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = ivk.Close() })
+
+	// no runtime waits invocation.
+	err = ivk.Invoke(context.Background(), Message{ID: "id:1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "no lambda runtime takes invocation")
+
+	// runtime takes invocation, but doesn't respond in time.
+	requestIDs := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ivk.Addr() + "/2018-06-01/runtime/invocation/next")
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+		requestIDs <- resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+	}()
+	err = ivk.Invoke(context.Background(), Message{ID: "id:2"})
+	assert.Contains(t, err.Error(), "lambda runtime doesn't respond")
+
+	// late response is rejected.
+	resp, err := http.Post("http://"+ivk.Addr()+"/2018-06-01/runtime/invocation/"+<-requestIDs+"/response", "application/json", strings.NewReader(`{}`))
+	assert.NoError(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(b), "InvalidRequestID")
+
+	resp, err = http.Post("http://"+ivk.Addr()+"/2018-06-01/runtime/init/error", "application/json", strings.NewReader(`{"errorType":"Runtime.ExitError","errorMessage":"exit"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, err = http.Get("http://" + ivk.Addr() + "/unknown")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,250 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen shows that locker backend is not called because circuit breaker is open.
+var ErrCircuitOpen = errors.New("locker circuit is open")
+
+// CircuitState represents state of CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed shows that locker backend is healthy and called as usual.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen shows that locker backend is not called until cooldown passes.
+	CircuitOpen
+	// CircuitHalfOpen shows that one trial call is allowed to check recovery of backend.
+	CircuitHalfOpen
+)
+
+// String returns lower case name of state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Health shows current health of locker backend.
+type Health struct {
+	State               CircuitState
+	ConsecutiveFailures int
+	LastError           error
+	LastFailureAt       time.Time
+	OpenedAt            time.Time
+}
+
+// HealthReporter is implemented by QueueLocker which reports health of its backend.
+type HealthReporter interface {
+	Health() Health
+}
+
+// CircuitBreaker wraps QueueLocker and stops calling it while its backend is down.
+type CircuitBreaker struct {
+	locker    QueueLocker
+	threshold int
+	cooldown  time.Duration
+
+	mu     sync.Mutex
+	health Health
+	trial  bool
+}
+
+var (
+	_ QueueLocker    = (*CircuitBreaker)(nil)
+	_ KeyReleaser    = (*CircuitBreaker)(nil)
+	_ Inspector      = (*CircuitBreaker)(nil)
+	_ CountUnlocker  = (*CircuitBreaker)(nil)
+	_ HealthReporter = (*CircuitBreaker)(nil)
+)
+
+// CircuitBreakerOption is an option for CircuitBreaker.
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// FailureThreshold sets count of consecutive failures to open circuit.
+func FailureThreshold(n int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.threshold = n
+	}
+}
+
+// Cooldown sets duration to keep circuit open before trial call.
+func Cooldown(dur time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.cooldown = dur
+	}
+}
+
+// NewCircuitBreaker creates CircuitBreaker.
+// As default, circuit opens by 5 consecutive failures and cooldown duration is 30 seconds.
+// ErrQueueExists, ErrLockNotFound and ErrInvalidRange are not treated as failure because backend responds correctly.
+func NewCircuitBreaker(l QueueLocker, opts ...CircuitBreakerOption) (*CircuitBreaker, error) {
+	if l == nil {
+		return nil, errors.New("locker is required")
+	}
+	b := &CircuitBreaker{
+		locker:    l,
+		threshold: 5,
+		cooldown:  30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.threshold <= 0 {
+		return nil, errors.New("threshold must be greater than 0")
+	}
+	return b, nil
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.health.State {
+	case CircuitOpen:
+		if time.Since(b.health.OpenedAt) < b.cooldown {
+			return false
+		}
+		b.health.State = CircuitHalfOpen
+		b.trial = true
+		return true
+	case CircuitHalfOpen:
+		// only one trial call is allowed at once.
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil || errors.Is(err, ErrQueueExists) || errors.Is(err, ErrLockNotFound) || errors.Is(err, ErrInvalidRange) {
+		b.health.State = CircuitClosed
+		b.health.ConsecutiveFailures = 0
+		return
+	}
+	now := time.Now().UTC()
+	b.health.ConsecutiveFailures++
+	b.health.LastError = err
+	b.health.LastFailureAt = now
+	if b.health.State == CircuitHalfOpen || b.health.ConsecutiveFailures >= b.threshold {
+		b.health.State = CircuitOpen
+		b.health.OpenedAt = now
+	}
+}
+
+func (b *CircuitBreaker) call(fn func() error) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+// Lock calls Lock of wrapped locker unless circuit is open.
+func (b *CircuitBreaker) Lock(ctx context.Context, key string) error {
+	return b.call(func() error {
+		return b.locker.Lock(ctx, key)
+	})
+}
+
+// Unlock calls Unlock of wrapped locker unless circuit is open.
+func (b *CircuitBreaker) Unlock(ctx context.Context, before time.Time) error {
+	return b.call(func() error {
+		return b.locker.Unlock(ctx, before)
+	})
+}
+
+// UnlockCount calls UnlockCount of wrapped locker unless circuit is open.
+// If wrapped locker doesn't implement CountUnlocker, Unlock is called and -1 is returned as count.
+func (b *CircuitBreaker) UnlockCount(ctx context.Context, before time.Time) (n int64, err error) {
+	cu, ok := b.locker.(CountUnlocker)
+	if !ok {
+		return -1, b.Unlock(ctx, before)
+	}
+	err = b.call(func() (err error) {
+		n, err = cu.UnlockCount(ctx, before)
+		return err
+	})
+	return n, err
+}
+
+// Release calls Release of wrapped locker unless circuit is open.
+// If wrapped locker doesn't implement KeyReleaser, ErrNotSupported is returned.
+func (b *CircuitBreaker) Release(ctx context.Context, key string) error {
+	r, ok := b.locker.(KeyReleaser)
+	if !ok {
+		return ErrNotSupported
+	}
+	return b.call(func() error {
+		return r.Release(ctx, key)
+	})
+}
+
+// List calls List of wrapped locker unless circuit is open.
+// If wrapped locker doesn't implement Inspector, ErrNotSupported is returned.
+func (b *CircuitBreaker) List(ctx context.Context, offset, limit int64) (entries []Entry, err error) {
+	i, ok := b.locker.(Inspector)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	err = b.call(func() (err error) {
+		entries, err = i.List(ctx, offset, limit)
+		return err
+	})
+	return entries, err
+}
+
+// Get calls Get of wrapped locker unless circuit is open.
+// If wrapped locker doesn't implement Inspector, ErrNotSupported is returned.
+func (b *CircuitBreaker) Get(ctx context.Context, key string) (entry Entry, err error) {
+	i, ok := b.locker.(Inspector)
+	if !ok {
+		return Entry{}, ErrNotSupported
+	}
+	err = b.call(func() (err error) {
+		entry, err = i.Get(ctx, key)
+		return err
+	})
+	return entry, err
+}
+
+// Count calls Count of wrapped locker unless circuit is open.
+// If wrapped locker doesn't implement Inspector, ErrNotSupported is returned.
+func (b *CircuitBreaker) Count(ctx context.Context) (n int64, err error) {
+	i, ok := b.locker.(Inspector)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	err = b.call(func() (err error) {
+		n, err = i.Count(ctx)
+		return err
+	})
+	return n, err
+}
+
+// Health returns current health of wrapped locker.
+func (b *CircuitBreaker) Health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.health
+	if h.State == CircuitOpen && time.Since(h.OpenedAt) >= b.cooldown {
+		h.State = CircuitHalfOpen
+	}
+	return h
+}
@@ -0,0 +1,69 @@
+package locker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taiyoh/sqsd/locker"
+	nooplocker "github.com/taiyoh/sqsd/locker/noop"
+)
+
+type flakyLocker struct {
+	err   error
+	calls int
+}
+
+func (l *flakyLocker) Lock(context.Context, string) error {
+	l.calls++
+	return l.err
+}
+
+func (l *flakyLocker) Unlock(context.Context, time.Time) error {
+	l.calls++
+	return l.err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b, err := locker.NewCircuitBreaker(nil)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "locker is required")
+
+	b, err = locker.NewCircuitBreaker(nooplocker.Get(), locker.FailureThreshold(0))
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "threshold must be greater than 0")
+
+	errDown := errors.New("backend is down")
+	fl := &flakyLocker{err: errDown}
+	b, err = locker.NewCircuitBreaker(fl, locker.FailureThreshold(2), locker.Cooldown(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.ErrorIs(t, b.Lock(ctx, "q1"), errDown)
+	assert.Equal(t, locker.CircuitClosed, b.Health().State)
+	assert.ErrorIs(t, b.Lock(ctx, "q1"), errDown)
+	assert.Equal(t, locker.CircuitOpen, b.Health().State)
+	assert.Equal(t, 2, b.Health().ConsecutiveFailures)
+	assert.ErrorIs(t, b.Health().LastError, errDown)
+
+	assert.ErrorIs(t, b.Lock(ctx, "q1"), locker.ErrCircuitOpen)
+	assert.ErrorIs(t, b.Unlock(ctx, time.Now()), locker.ErrCircuitOpen)
+	assert.Equal(t, 2, fl.calls, "backend is not called while circuit is open")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, locker.CircuitHalfOpen, b.Health().State)
+
+	// trial call fails, so circuit opens again.
+	assert.ErrorIs(t, b.Lock(ctx, "q1"), errDown)
+	assert.Equal(t, locker.CircuitOpen, b.Health().State)
+	assert.Equal(t, 3, fl.calls)
+
+	time.Sleep(60 * time.Millisecond)
+	fl.err = locker.ErrQueueExists
+	assert.ErrorIs(t, b.Lock(ctx, "q1"), locker.ErrQueueExists)
+	assert.Equal(t, locker.CircuitClosed, b.Health().State)
+	assert.Equal(t, 0, b.Health().ConsecutiveFailures)
+}
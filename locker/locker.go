@@ -3,6 +3,9 @@ package locker
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -12,16 +15,73 @@ type QueueLocker interface {
 	Unlock(ctx context.Context, before time.Time) error
 }
 
+// KeyReleaser is implemented by QueueLocker which can release a single key before its expiration.
+type KeyReleaser interface {
+	Release(ctx context.Context, key string) error
+}
+
+// CountUnlocker is implemented by QueueLocker which reports count of unlocked keys.
+type CountUnlocker interface {
+	UnlockCount(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Entry represents the key held by QueueLocker.
+type Entry struct {
+	Key      string
+	LockedAt time.Time
+}
+
+// Inspector is implemented by QueueLocker which can show its held keys.
+type Inspector interface {
+	// List returns held keys ordered by locked time.
+	// limit 0 means no limit, and negative offset or limit returns ErrInvalidRange.
+	List(ctx context.Context, offset, limit int64) ([]Entry, error)
+	// Get returns ErrLockNotFound if key is not held.
+	Get(ctx context.Context, key string) (Entry, error)
+	Count(ctx context.Context) (int64, error)
+}
+
 const defaultExpireDuration = 24 * time.Hour
 
 // ErrQueueExists shows this queue is already registered.
 var ErrQueueExists = errors.New("queue exists")
 
+// ErrLockNotFound shows the key is not held by locker.
+var ErrLockNotFound = errors.New("lock not found")
+
+// ErrInvalidRange shows offset or limit of List is negative.
+var ErrInvalidRange = errors.New("offset and limit must not be negative")
+
+// ErrNotSupported shows wrapped locker doesn't support the operation.
+var ErrNotSupported = errors.New("operation is not supported by locker")
+
+// UnlockerStats shows result of sweeps by Unlocker.
+type UnlockerStats struct {
+	// LastSweepAt is the time when last successful sweep finished.
+	LastSweepAt time.Time
+	// LastRemoved is the count of keys removed by last successful sweep.
+	// if locker doesn't implement CountUnlocker, -1 is set.
+	LastRemoved int64
+	// LastDuration is the duration of last successful sweep.
+	LastDuration time.Duration
+	// TotalRemoved is the count of keys removed by all sweeps.
+	TotalRemoved int64
+	// ConsecutiveFailures is reset by successful sweep.
+	ConsecutiveFailures int
+	LastError           error
+}
+
 // Unlocker removes unused queue_id list periodically.
 type Unlocker struct {
-	interval time.Duration
-	expire   time.Duration
-	locker   QueueLocker
+	interval   time.Duration
+	expire     time.Duration
+	jitter     time.Duration
+	minBackoff time.Duration
+	locker     QueueLocker
+	logger     *slog.Logger
+
+	mu    sync.Mutex
+	stats UnlockerStats
 }
 
 // UnlockerOption is an option for Unlocker.
@@ -34,8 +94,32 @@ func ExpireDuration(dur time.Duration) UnlockerOption {
 	}
 }
 
+// Jitter sets max duration which is randomly added to each interval,
+// so that unlockers in many instances don't run at the same moment.
+func Jitter(dur time.Duration) UnlockerOption {
+	return func(u *Unlocker) {
+		u.jitter = dur
+	}
+}
+
+// RetryBackoff sets first wait duration to retry after failure.
+// The duration is doubled by consecutive failures up to interval.
+func RetryBackoff(dur time.Duration) UnlockerOption {
+	return func(u *Unlocker) {
+		u.minBackoff = dur
+	}
+}
+
+// UnlockerLogger sets logger to Unlocker.
+func UnlockerLogger(l *slog.Logger) UnlockerOption {
+	return func(u *Unlocker) {
+		u.logger = l
+	}
+}
+
 // NewUnlocker creates Unlocker.
-// As default, expire duration is 24 hours.
+// As default, expire duration is 24 hours, jitter is 10% of interval,
+// retry backoff is 1 second and logger is slog.Default().
 func NewUnlocker(l QueueLocker, interval time.Duration, opts ...UnlockerOption) (*Unlocker, error) {
 	if l == nil {
 		return nil, errors.New("locker is required")
@@ -44,29 +128,100 @@ func NewUnlocker(l QueueLocker, interval time.Duration, opts ...UnlockerOption)
 		return nil, errors.New("interval must be greater than 0")
 	}
 	ul := &Unlocker{
-		interval: interval,
-		expire:   defaultExpireDuration,
-		locker:   l,
+		interval:   interval,
+		expire:     defaultExpireDuration,
+		jitter:     interval / 10,
+		minBackoff: time.Second,
+		locker:     l,
+		logger:     slog.Default(),
 	}
 	for _, opt := range opts {
 		opt(ul)
 	}
+	if ul.jitter < 0 {
+		return nil, errors.New("jitter must not be negative")
+	}
+	if ul.minBackoff <= 0 {
+		return nil, errors.New("retry backoff must be greater than 0")
+	}
+	ul.stats.LastRemoved = -1
 	return ul, nil
 }
 
+// Stats returns result of sweeps.
+func (u *Unlocker) Stats() UnlockerStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.stats
+}
+
 // Run runs unlock operation by interval.
-func (u Unlocker) Run(ctx context.Context) {
-	tick := time.NewTicker(u.interval)
-	defer tick.Stop()
+// After failure, unlock operation is retried by exponential backoff.
+func (u *Unlocker) Run(ctx context.Context) {
+	timer := time.NewTimer(u.next(0))
+	defer timer.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-tick.C:
-			if err := u.locker.Unlock(ctx, time.Now().UTC().Add(-u.expire)); err != nil {
-				// TODO: logging
-				continue
-			}
+		case <-timer.C:
+			timer.Reset(u.next(u.sweep(ctx)))
 		}
 	}
 }
+
+// next returns wait duration until next sweep by count of consecutive failures.
+func (u *Unlocker) next(failures int) time.Duration {
+	d := u.interval
+	if failures > 0 {
+		d = u.minBackoff
+		for i := 1; i < failures && d < u.interval; i++ {
+			d *= 2
+		}
+		if d > u.interval {
+			d = u.interval
+		}
+	}
+	if u.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(u.jitter) + 1))
+	}
+	return d
+}
+
+// sweep removes expired keys and returns count of consecutive failures.
+func (u *Unlocker) sweep(ctx context.Context) int {
+	started := time.Now()
+	before := started.UTC().Add(-u.expire)
+	removed := int64(-1)
+	var err error
+	if cu, ok := u.locker.(CountUnlocker); ok {
+		removed, err = cu.UnlockCount(ctx, before)
+	} else {
+		err = u.locker.Unlock(ctx, before)
+	}
+	elapsed := time.Since(started)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err != nil {
+		u.stats.ConsecutiveFailures++
+		u.stats.LastError = err
+		if ctx.Err() == nil {
+			u.logger.Warn("failed to unlock.",
+				"error", err,
+				"consecutive_failures", u.stats.ConsecutiveFailures,
+				"elapsed", elapsed.String())
+		}
+		return u.stats.ConsecutiveFailures
+	}
+	u.stats.LastSweepAt = time.Now().UTC()
+	u.stats.LastRemoved = removed
+	u.stats.LastDuration = elapsed
+	u.stats.ConsecutiveFailures = 0
+	u.stats.LastError = nil
+	if removed > 0 {
+		u.stats.TotalRemoved += removed
+	}
+	u.logger.Debug("unlocked expired keys.", "removed", removed, "elapsed", elapsed.String())
+	return 0
+}
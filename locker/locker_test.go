@@ -1,13 +1,17 @@
 package locker_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/taiyoh/sqsd/locker"
+	memorylocker "github.com/taiyoh/sqsd/locker/memory"
 	nooplocker "github.com/taiyoh/sqsd/locker/noop"
 )
 
@@ -53,3 +57,62 @@ func TestUnlocker(t *testing.T) {
 	}
 	assert.Equal(t, 3, counter)
 }
+
+func TestUnlockerStats(t *testing.T) {
+	l, err := locker.NewUnlocker(nooplocker.Get(), time.Second, locker.Jitter(-1))
+	assert.Nil(t, l)
+	assert.EqualError(t, err, "jitter must not be negative")
+
+	t.Run("succeeded", func(t *testing.T) {
+		ml := memorylocker.New()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		assert.NoError(t, ml.Lock(ctx, "q1"))
+		assert.NoError(t, ml.Lock(ctx, "q2"))
+
+		l, err := locker.NewUnlocker(ml, 10*time.Millisecond, locker.ExpireDuration(0), locker.Jitter(0))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-1), l.Stats().LastRemoved)
+		assert.True(t, l.Stats().LastSweepAt.IsZero())
+
+		go l.Run(ctx)
+
+		assert.Eventually(t, func() bool {
+			return l.Stats().TotalRemoved == 2
+		}, time.Second, 5*time.Millisecond)
+		stats := l.Stats()
+		assert.False(t, stats.LastSweepAt.IsZero())
+		assert.Zero(t, stats.ConsecutiveFailures)
+		assert.NoError(t, stats.LastError)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		errDown := errors.New("backend is down")
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		ctx, cancel := context.WithCancel(context.Background())
+
+		l, err := locker.NewUnlocker(&flakyLocker{err: errDown}, 20*time.Millisecond,
+			locker.RetryBackoff(time.Millisecond),
+			locker.UnlockerLogger(logger))
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Run(ctx)
+		}()
+
+		assert.Eventually(t, func() bool {
+			return l.Stats().ConsecutiveFailures >= 3
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		wg.Wait()
+
+		stats := l.Stats()
+		assert.True(t, stats.LastSweepAt.IsZero())
+		assert.ErrorIs(t, stats.LastError, errDown)
+		assert.Contains(t, buf.String(), "failed to unlock.")
+	})
+}
@@ -2,6 +2,7 @@ package memorylocker
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,7 +18,12 @@ func New() locker.QueueLocker {
 	return &memoryLocker{}
 }
 
-var _ locker.QueueLocker = (*memoryLocker)(nil)
+var (
+	_ locker.QueueLocker   = (*memoryLocker)(nil)
+	_ locker.KeyReleaser   = (*memoryLocker)(nil)
+	_ locker.Inspector     = (*memoryLocker)(nil)
+	_ locker.CountUnlocker = (*memoryLocker)(nil)
+)
 
 func (l *memoryLocker) Lock(_ context.Context, queueID string) error {
 	now := time.Now().UTC()
@@ -28,7 +34,12 @@ func (l *memoryLocker) Lock(_ context.Context, queueID string) error {
 	return nil
 }
 
-func (l *memoryLocker) Unlock(_ context.Context, ts time.Time) error {
+func (l *memoryLocker) Unlock(ctx context.Context, ts time.Time) error {
+	_, err := l.UnlockCount(ctx, ts)
+	return err
+}
+
+func (l *memoryLocker) UnlockCount(_ context.Context, ts time.Time) (int64, error) {
 	var keys []interface{}
 	l.pool.Range(func(key, value interface{}) bool {
 		if value.(time.Time).Before(ts) {
@@ -39,5 +50,49 @@ func (l *memoryLocker) Unlock(_ context.Context, ts time.Time) error {
 	for _, key := range keys {
 		l.pool.Delete(key)
 	}
+	return int64(len(keys)), nil
+}
+
+func (l *memoryLocker) Release(_ context.Context, queueID string) error {
+	l.pool.Delete(queueID)
 	return nil
 }
+
+func (l *memoryLocker) List(_ context.Context, offset, limit int64) ([]locker.Entry, error) {
+	if offset < 0 || limit < 0 {
+		return nil, locker.ErrInvalidRange
+	}
+	var entries []locker.Entry
+	l.pool.Range(func(key, value interface{}) bool {
+		entries = append(entries, locker.Entry{Key: key.(string), LockedAt: value.(time.Time)})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LockedAt.Before(entries[j].LockedAt)
+	})
+	if offset >= int64(len(entries)) {
+		return []locker.Entry{}, nil
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < int64(len(entries)) {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (l *memoryLocker) Get(_ context.Context, queueID string) (locker.Entry, error) {
+	v, ok := l.pool.Load(queueID)
+	if !ok {
+		return locker.Entry{}, locker.ErrLockNotFound
+	}
+	return locker.Entry{Key: queueID, LockedAt: v.(time.Time)}, nil
+}
+
+func (l *memoryLocker) Count(_ context.Context) (int64, error) {
+	var n int64
+	l.pool.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n, nil
+}
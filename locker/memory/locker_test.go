@@ -15,6 +15,8 @@ func TestMemoryLocker(t *testing.T) {
 
 	assert.NoError(t, l.Lock(ctx, "hogefuga"))
 	assert.ErrorIs(t, l.Lock(ctx, "hogefuga"), locker.ErrQueueExists)
+	assert.NoError(t, l.(locker.KeyReleaser).Release(ctx, "hogefuga"))
+	assert.NoError(t, l.Lock(ctx, "hogefuga"))
 
 	t1 := time.Now().UTC()
 
@@ -56,3 +58,47 @@ func TestMemoryLocker(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryLockerInspector(t *testing.T) {
+	l := New()
+	ctx := context.Background()
+	i := l.(locker.Inspector)
+
+	now := time.Now().UTC()
+	ll := l.(*memoryLocker)
+	ll.pool.Store("q3", now)
+	ll.pool.Store("q1", now.Add(-2*time.Second))
+	ll.pool.Store("q2", now.Add(-1*time.Second))
+
+	n, err := i.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	entries, err := i.List(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []locker.Entry{
+		{Key: "q1", LockedAt: now.Add(-2 * time.Second)},
+		{Key: "q2", LockedAt: now.Add(-1 * time.Second)},
+		{Key: "q3", LockedAt: now},
+	}, entries)
+
+	entries, err = i.List(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []locker.Entry{{Key: "q2", LockedAt: now.Add(-1 * time.Second)}}, entries)
+
+	entries, err = i.List(ctx, 5, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = i.List(ctx, -1, 1)
+	assert.ErrorIs(t, err, locker.ErrInvalidRange)
+	_, err = i.List(ctx, 0, -1)
+	assert.ErrorIs(t, err, locker.ErrInvalidRange)
+
+	e, err := i.Get(ctx, "q3")
+	assert.NoError(t, err)
+	assert.Equal(t, locker.Entry{Key: "q3", LockedAt: now}, e)
+
+	_, err = i.Get(ctx, "q4")
+	assert.ErrorIs(t, err, locker.ErrLockNotFound)
+}
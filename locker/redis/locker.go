@@ -3,6 +3,7 @@ package redislocker
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/rueidis"
@@ -14,7 +15,12 @@ type redislocker struct {
 	cli     rueidis.Client
 }
 
-var _ locker.QueueLocker = (*redislocker)(nil)
+var (
+	_ locker.QueueLocker   = (*redislocker)(nil)
+	_ locker.KeyReleaser   = (*redislocker)(nil)
+	_ locker.Inspector     = (*redislocker)(nil)
+	_ locker.CountUnlocker = (*redislocker)(nil)
+)
 
 // New creates QueueLocker by Redis.
 func New(cli rueidis.Client, keyName string) locker.QueueLocker {
@@ -45,6 +51,58 @@ func (l *redislocker) Lock(ctx context.Context, queueID string) error {
 }
 
 func (l *redislocker) Unlock(ctx context.Context, ts time.Time) error {
+	_, err := l.UnlockCount(ctx, ts)
+	return err
+}
+
+func (l *redislocker) UnlockCount(ctx context.Context, ts time.Time) (int64, error) {
 	cmd := l.cli.B().Zremrangebyscore().Key(l.keyName).Min("-inf").Max(fmt.Sprintf("%d", ts.UnixNano()))
+	return l.cli.Do(ctx, cmd.Build()).AsInt64()
+}
+
+func (l *redislocker) Release(ctx context.Context, queueID string) error {
+	cmd := l.cli.B().Zrem().Key(l.keyName).Member(queueID)
 	return l.cli.Do(ctx, cmd.Build()).Error()
 }
+
+func (l *redislocker) List(ctx context.Context, offset, limit int64) ([]locker.Entry, error) {
+	// negative index of ZRANGE counts from the end, which is not allowed as well as memory locker.
+	if offset < 0 || limit < 0 {
+		return nil, locker.ErrInvalidRange
+	}
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	cmd := l.cli.B().Zrange().Key(l.keyName).Min(strconv.FormatInt(offset, 10)).Max(strconv.FormatInt(stop, 10)).Withscores()
+	scores, err := l.cli.Do(ctx, cmd.Build()).AsZScores()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]locker.Entry, 0, len(scores))
+	for _, s := range scores {
+		entries = append(entries, locker.Entry{Key: s.Member, LockedAt: scoreToTime(s.Score)})
+	}
+	return entries, nil
+}
+
+func (l *redislocker) Get(ctx context.Context, queueID string) (locker.Entry, error) {
+	cmd := l.cli.B().Zscore().Key(l.keyName).Member(queueID)
+	score, err := l.cli.Do(ctx, cmd.Build()).AsFloat64()
+	if rueidis.IsRedisNil(err) {
+		return locker.Entry{}, locker.ErrLockNotFound
+	}
+	if err != nil {
+		return locker.Entry{}, err
+	}
+	return locker.Entry{Key: queueID, LockedAt: scoreToTime(score)}, nil
+}
+
+func (l *redislocker) Count(ctx context.Context) (int64, error) {
+	cmd := l.cli.B().Zcard().Key(l.keyName)
+	return l.cli.Do(ctx, cmd.Build()).AsInt64()
+}
+
+func scoreToTime(score float64) time.Time {
+	return time.Unix(0, int64(score)).UTC()
+}
@@ -75,4 +75,46 @@ func TestRedsislocker(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Empty(t, ids)
 	})
+
+	t.Run("q3 released", func(t *testing.T) {
+		assert.NoError(t, obj.Lock(ctx, "q3"))
+		assert.NoError(t, obj.(locker.KeyReleaser).Release(ctx, "q3"))
+		result := cli.Do(ctx, cli.B().Zrangebyscore().Key("testKey").Min("-inf").Max("+inf").Build())
+		assert.NoError(t, result.Error())
+		ids, err := result.AsStrSlice()
+		assert.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		assert.NoError(t, obj.Lock(ctx, "q4"))
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, obj.Lock(ctx, "q5"))
+		i := obj.(locker.Inspector)
+
+		n, err := i.Count(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		entries, err := i.List(ctx, 0, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 2) {
+			assert.Equal(t, "q4", entries[0].Key)
+			assert.Equal(t, "q5", entries[1].Key)
+		}
+
+		entries, err = i.List(ctx, 1, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "q5", entries[0].Key)
+		}
+
+		e, err := i.Get(ctx, "q4")
+		assert.NoError(t, err)
+		assert.Equal(t, "q4", e.Key)
+		assert.WithinDuration(t, time.Now(), e.LockedAt, time.Second)
+
+		_, err = i.Get(ctx, "q6")
+		assert.ErrorIs(t, err, locker.ErrLockNotFound)
+	})
 }
@@ -0,0 +1,165 @@
+package tieredlocker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/taiyoh/sqsd/locker"
+)
+
+// Stats shows how many Lock calls are resolved by each tier.
+type Stats struct {
+	// LocalHits is the count of duplicated keys found in local tier.
+	LocalHits uint64
+	// SharedHits is the count of duplicated keys found in shared tier.
+	SharedHits uint64
+	// Misses is the count of keys newly locked in both tiers.
+	Misses uint64
+	// Errors is the count of failures returned from shared tier.
+	Errors uint64
+}
+
+// LockerWithStats has tieredLocker instance which reports its statistics.
+type LockerWithStats interface {
+	locker.QueueLocker
+	locker.KeyReleaser
+	locker.Inspector
+	locker.CountUnlocker
+	Stats() Stats
+}
+
+type tieredLocker struct {
+	local      locker.QueueLocker
+	shared     locker.QueueLocker
+	localHits  atomic.Uint64
+	sharedHits atomic.Uint64
+	misses     atomic.Uint64
+	errors     atomic.Uint64
+}
+
+var _ LockerWithStats = (*tieredLocker)(nil)
+
+// New creates QueueLocker which checks local tier at first and shared tier next.
+// local tier is typically memory locker, and shared tier is typically redis locker.
+// local tier caches only keys which this instance locked: when shared tier fails to lock or holds the key already,
+// the key is released from local tier if local tier implements locker.KeyReleaser,
+// so that the message can be locked again on next receive.
+func New(local, shared locker.QueueLocker) LockerWithStats {
+	return &tieredLocker{
+		local:  local,
+		shared: shared,
+	}
+}
+
+func (l *tieredLocker) Lock(ctx context.Context, key string) error {
+	switch err := l.local.Lock(ctx, key); err {
+	case nil:
+	case locker.ErrQueueExists:
+		l.localHits.Add(1)
+		return err
+	default:
+		return err
+	}
+	switch err := l.shared.Lock(ctx, key); err {
+	case nil:
+		l.misses.Add(1)
+		return nil
+	case locker.ErrQueueExists:
+		// local tier caches only keys locked by this instance,
+		// because release of key by other instance can't clear local tier of this instance.
+		l.sharedHits.Add(1)
+		l.releaseLocal(ctx, key)
+		return err
+	default:
+		l.errors.Add(1)
+		l.releaseLocal(ctx, key)
+		return err
+	}
+}
+
+// releaseLocal releases key from local tier if local tier implements locker.KeyReleaser.
+func (l *tieredLocker) releaseLocal(ctx context.Context, key string) {
+	if r, ok := l.local.(locker.KeyReleaser); ok {
+		_ = r.Release(ctx, key)
+	}
+}
+
+// Unlock propagates to both tiers even if one of them fails.
+func (l *tieredLocker) Unlock(ctx context.Context, before time.Time) error {
+	return errors.Join(
+		l.local.Unlock(ctx, before),
+		l.shared.Unlock(ctx, before),
+	)
+}
+
+// UnlockCount propagates to both tiers even if one of them fails.
+// Returned count is from shared tier, or -1 if shared tier doesn't implement locker.CountUnlocker.
+func (l *tieredLocker) UnlockCount(ctx context.Context, before time.Time) (int64, error) {
+	localErr := l.local.Unlock(ctx, before)
+	cu, ok := l.shared.(locker.CountUnlocker)
+	if !ok {
+		return -1, errors.Join(localErr, l.shared.Unlock(ctx, before))
+	}
+	n, err := cu.UnlockCount(ctx, before)
+	return n, errors.Join(localErr, err)
+}
+
+// Release propagates to tiers which implement locker.KeyReleaser.
+// If no tier implements it, locker.ErrNotSupported is returned.
+func (l *tieredLocker) Release(ctx context.Context, key string) error {
+	var errs []error
+	for _, ql := range []locker.QueueLocker{l.local, l.shared} {
+		if r, ok := ql.(locker.KeyReleaser); ok {
+			errs = append(errs, r.Release(ctx, key))
+		}
+	}
+	if len(errs) == 0 {
+		return locker.ErrNotSupported
+	}
+	return errors.Join(errs...)
+}
+
+// inspector returns shared tier because it holds keys of all instances.
+// If shared tier doesn't implement locker.Inspector, local tier is used.
+func (l *tieredLocker) inspector() (locker.Inspector, bool) {
+	if i, ok := l.shared.(locker.Inspector); ok {
+		return i, true
+	}
+	i, ok := l.local.(locker.Inspector)
+	return i, ok
+}
+
+func (l *tieredLocker) List(ctx context.Context, offset, limit int64) ([]locker.Entry, error) {
+	i, ok := l.inspector()
+	if !ok {
+		return nil, locker.ErrNotSupported
+	}
+	return i.List(ctx, offset, limit)
+}
+
+func (l *tieredLocker) Get(ctx context.Context, key string) (locker.Entry, error) {
+	i, ok := l.inspector()
+	if !ok {
+		return locker.Entry{}, locker.ErrNotSupported
+	}
+	return i.Get(ctx, key)
+}
+
+func (l *tieredLocker) Count(ctx context.Context) (int64, error) {
+	i, ok := l.inspector()
+	if !ok {
+		return 0, locker.ErrNotSupported
+	}
+	return i.Count(ctx)
+}
+
+func (l *tieredLocker) Stats() Stats {
+	return Stats{
+		LocalHits:  l.localHits.Load(),
+		SharedHits: l.sharedHits.Load(),
+		Misses:     l.misses.Load(),
+		Errors:     l.errors.Load(),
+	}
+}
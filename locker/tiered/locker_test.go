@@ -0,0 +1,79 @@
+package tieredlocker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taiyoh/sqsd/locker"
+	memorylocker "github.com/taiyoh/sqsd/locker/memory"
+	nooplocker "github.com/taiyoh/sqsd/locker/noop"
+)
+
+type failingLocker struct {
+	err error
+}
+
+func (l failingLocker) Lock(context.Context, string) error {
+	return l.err
+}
+
+func (l failingLocker) Unlock(context.Context, time.Time) error {
+	return l.err
+}
+
+func TestTieredLocker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hit and miss", func(t *testing.T) {
+		shared := memorylocker.New()
+		l1 := New(memorylocker.New(), shared)
+		l2 := New(memorylocker.New(), shared)
+
+		assert.NoError(t, l1.Lock(ctx, "q1"))
+		assert.ErrorIs(t, l1.Lock(ctx, "q1"), locker.ErrQueueExists)
+		assert.ErrorIs(t, l2.Lock(ctx, "q1"), locker.ErrQueueExists)
+		assert.ErrorIs(t, l2.Lock(ctx, "q1"), locker.ErrQueueExists)
+
+		// key held by other instance is not cached in local tier.
+		assert.Equal(t, Stats{LocalHits: 1, Misses: 1}, l1.Stats())
+		assert.Equal(t, Stats{SharedHits: 2}, l2.Stats())
+
+		// key released by other instance can be locked immediately.
+		assert.NoError(t, l1.Release(ctx, "q1"))
+		assert.NoError(t, l2.Lock(ctx, "q1"))
+		assert.ErrorIs(t, l1.Lock(ctx, "q1"), locker.ErrQueueExists)
+	})
+
+	t.Run("shared error releases local", func(t *testing.T) {
+		errShared := errors.New("shared is down")
+		local := memorylocker.New()
+		l := New(local, failingLocker{err: errShared})
+
+		assert.ErrorIs(t, l.Lock(ctx, "q1"), errShared)
+		assert.NoError(t, local.Lock(ctx, "q1"))
+		assert.Equal(t, Stats{Errors: 1}, l.Stats())
+
+		assert.ErrorIs(t, l.Unlock(ctx, time.Now()), errShared)
+		assert.NoError(t, local.Lock(ctx, "q1"), "local tier is unlocked even if shared tier fails")
+	})
+
+	t.Run("unlock propagates", func(t *testing.T) {
+		local := nooplocker.Get()
+		shared := nooplocker.Get()
+		var called []string
+		local.AddUnlockHook(func(context.Context, time.Time) error {
+			called = append(called, "local")
+			return nil
+		})
+		shared.AddUnlockHook(func(context.Context, time.Time) error {
+			called = append(called, "shared")
+			return nil
+		})
+		l := New(local, shared)
+		assert.NoError(t, l.Unlock(ctx, time.Now()))
+		assert.Equal(t, []string{"local", "shared"}, called)
+	})
+}
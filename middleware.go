@@ -0,0 +1,116 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// InvokerFunc is an adapter to use function as Invoker.
+type InvokerFunc func(context.Context, Message) error
+
+// Invoke calls f(ctx, q).
+func (f InvokerFunc) Invoke(ctx context.Context, q Message) error {
+	return f(ctx, q)
+}
+
+// Middleware wraps Invoker to add cross-cutting behavior.
+type Middleware func(Invoker) Invoker
+
+// Chain wraps ivk by middlewares. The first middleware is the outermost,
+// so Chain(ivk, a, b) calls a, b and ivk in this order.
+func Chain(ivk Invoker, mws ...Middleware) Invoker {
+	for i := len(mws) - 1; i >= 0; i-- {
+		ivk = mws[i](ivk)
+	}
+	return ivk
+}
+
+// PanicError is returned when invoker panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error implements error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("invoker panics: %v", e.Value)
+}
+
+// recoverPanic converts panic in fn to PanicError.
+func recoverPanic(fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Recovery returns Middleware which recovers panic of invoker and returns it as PanicError.
+func Recovery() Middleware {
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			return recoverPanic(func() error {
+				return next.Invoke(ctx, q)
+			})
+		})
+	}
+}
+
+// Timeout returns Middleware which cancels context of invocation after d.
+func Timeout(d time.Duration) Middleware {
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Invoke(ctx, q)
+		})
+	}
+}
+
+// Logging returns Middleware which logs result and elapsed time of invocation.
+// If logger is nil, default logger of sqsd is used.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			l := logger
+			if l == nil {
+				l = getLogger()
+			}
+			l = l.With("message_id", q.ID)
+			started := time.Now()
+			err := next.Invoke(ctx, q)
+			elapsed := time.Since(started).String()
+			switch {
+			case err == nil:
+				l.InfoContext(ctx, "invocation succeeded", "elapsed", elapsed)
+			case errors.Is(err, ErrRetainMessage):
+				l.InfoContext(ctx, "invocation retained message", "elapsed", elapsed)
+			default:
+				l.WarnContext(ctx, "invocation failed", "elapsed", elapsed, "error", err)
+			}
+			return err
+		})
+	}
+}
+
+// ConcurrencyLimit returns Middleware which limits count of concurrent invocations to n.
+// Invocation waits until other invocation ends or its context is done.
+func ConcurrencyLimit(n int) Middleware {
+	sem := semaphore.NewWeighted(int64(n))
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			return next.Invoke(ctx, q)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Invoker) Invoker {
+			return InvokerFunc(func(ctx context.Context, q Message) error {
+				calls = append(calls, name)
+				return next.Invoke(ctx, q)
+			})
+		}
+	}
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		calls = append(calls, "invoker")
+		return nil
+	}), mw("a"), mw("b"))
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}))
+	assert.Equal(t, []string{"a", "b", "invoker"}, calls)
+}
+
+func TestRecovery(t *testing.T) {
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		panic("boom")
+	}), Recovery())
+	err := ivk.Invoke(context.Background(), Message{ID: "id:1"})
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestRecovery")
+	assert.EqualError(t, err, "invoker panics: boom")
+}
+
+func TestTimeout(t *testing.T) {
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), Timeout(10*time.Millisecond))
+	assert.ErrorIs(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}), context.DeadlineExceeded)
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	errs := map[string]error{"ok": nil, "retain": ErrRetainMessage, "failure": errors.New("failure")}
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		return errs[q.ID]
+	}), Logging(logger))
+
+	for _, id := range []string{"ok", "retain", "failure"} {
+		assert.ErrorIs(t, ivk.Invoke(context.Background(), Message{ID: id}), errs[id])
+	}
+	out := buf.String()
+	assert.Contains(t, out, `msg="invocation succeeded" message_id=ok`)
+	assert.Contains(t, out, `msg="invocation retained message" message_id=retain`)
+	assert.Contains(t, out, `level=WARN msg="invocation failed" message_id=failure`)
+	assert.Contains(t, out, `error=failure`)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var current, peak atomic.Int64
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}), ConcurrencyLimit(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(2), peak.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := Chain(InvokerFunc(func(ctx context.Context, q Message) error { return nil }), ConcurrencyLimit(0))
+	assert.ErrorIs(t, blocked.Invoke(ctx, Message{ID: "id:1"}), context.Canceled)
+}
@@ -2,13 +2,25 @@ package sqsd
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/taiyoh/sqsd/locker"
 )
 
 // MonitoringService provides grpc handler for MonitoringService.
 type MonitoringService struct {
 	UnimplementedMonitoringServiceServer
-	worker *worker
+	worker   *worker
+	locker   locker.QueueLocker
+	unlocker *locker.Unlocker
+	balancer EndpointReporter
+	canary   CanaryController
 }
 
 // NewMonitoringService returns new MonitoringService object.
@@ -24,6 +36,154 @@ func (s *MonitoringService) CurrentWorkings(ctx context.Context, _ *CurrentWorki
 	return &CurrentWorkingsResponse{Tasks: tasks}, nil
 }
 
+var circuitStates = map[locker.CircuitState]CircuitState{
+	locker.CircuitClosed:   CircuitState_CIRCUIT_STATE_CLOSED,
+	locker.CircuitOpen:     CircuitState_CIRCUIT_STATE_OPEN,
+	locker.CircuitHalfOpen: CircuitState_CIRCUIT_STATE_HALF_OPEN,
+}
+
+// LockerHealth handles LockerHealth grpc request.
+// If locker doesn't implement locker.HealthReporter, response is returned as not reported.
+func (s *MonitoringService) LockerHealth(ctx context.Context, _ *LockerHealthRequest) (*LockerHealthResponse, error) {
+	hr, ok := s.locker.(locker.HealthReporter)
+	if !ok {
+		return &LockerHealthResponse{}, nil
+	}
+	h := hr.Health()
+	resp := &LockerHealthResponse{
+		Reported:            true,
+		State:               circuitStates[h.State],
+		ConsecutiveFailures: int64(h.ConsecutiveFailures),
+	}
+	if h.LastError != nil {
+		resp.LastError = h.LastError.Error()
+	}
+	if !h.LastFailureAt.IsZero() {
+		resp.LastFailureAt = timestamppb.New(h.LastFailureAt)
+	}
+	if !h.OpenedAt.IsZero() {
+		resp.OpenedAt = timestamppb.New(h.OpenedAt)
+	}
+	return resp, nil
+}
+
+// defaultListLocksLimit is used when ListLocksRequest has no limit.
+const defaultListLocksLimit = 100
+
+func lockerStatusError(err error) error {
+	switch {
+	case errors.Is(err, locker.ErrNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, locker.ErrLockNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, locker.ErrCircuitOpen):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, locker.ErrInvalidRange):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *MonitoringService) inspector() (locker.Inspector, error) {
+	i, ok := s.locker.(locker.Inspector)
+	if !ok {
+		return nil, lockerStatusError(locker.ErrNotSupported)
+	}
+	return i, nil
+}
+
+func newLockEntry(e locker.Entry) *LockEntry {
+	return &LockEntry{
+		Key:      e.Key,
+		LockedAt: timestamppb.New(e.LockedAt),
+	}
+}
+
+// ListLocks handles ListLocks grpc request.
+// Locks are ordered by locked time, and at most 100 locks are returned if limit is not supplied.
+func (s *MonitoringService) ListLocks(ctx context.Context, req *ListLocksRequest) (*ListLocksResponse, error) {
+	if req.GetOffset() < 0 || req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+	i, err := s.inspector()
+	if err != nil {
+		return nil, err
+	}
+	limit := req.GetLimit()
+	if limit <= 0 {
+		limit = defaultListLocksLimit
+	}
+	entries, err := i.List(ctx, req.GetOffset(), limit)
+	if err != nil {
+		return nil, lockerStatusError(err)
+	}
+	total, err := i.Count(ctx)
+	if err != nil {
+		return nil, lockerStatusError(err)
+	}
+	locks := make([]*LockEntry, 0, len(entries))
+	for _, e := range entries {
+		locks = append(locks, newLockEntry(e))
+	}
+	return &ListLocksResponse{Locks: locks, Total: total}, nil
+}
+
+// GetLock handles GetLock grpc request.
+func (s *MonitoringService) GetLock(ctx context.Context, req *GetLockRequest) (*GetLockResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	i, err := s.inspector()
+	if err != nil {
+		return nil, err
+	}
+	e, err := i.Get(ctx, req.GetKey())
+	if err != nil {
+		return nil, lockerStatusError(err)
+	}
+	return &GetLockResponse{Lock: newLockEntry(e)}, nil
+}
+
+// ReleaseLock handles ReleaseLock grpc request.
+// Released key can be locked again, so that message with the key will be processed when it is received.
+func (s *MonitoringService) ReleaseLock(ctx context.Context, req *ReleaseLockRequest) (*ReleaseLockResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	r, ok := s.locker.(locker.KeyReleaser)
+	if !ok {
+		return nil, lockerStatusError(locker.ErrNotSupported)
+	}
+	if err := r.Release(ctx, req.GetKey()); err != nil {
+		return nil, lockerStatusError(err)
+	}
+	getLogger().Info("lock is released by request.", "key", req.GetKey())
+	return &ReleaseLockResponse{}, nil
+}
+
+// UnlockerStatus handles UnlockerStatus grpc request.
+// If unlocker doesn't run in system, response is returned as not running.
+func (s *MonitoringService) UnlockerStatus(ctx context.Context, _ *UnlockerStatusRequest) (*UnlockerStatusResponse, error) {
+	if s.unlocker == nil {
+		return &UnlockerStatusResponse{}, nil
+	}
+	stats := s.unlocker.Stats()
+	resp := &UnlockerStatusResponse{
+		Running:             true,
+		LastRemoved:         stats.LastRemoved,
+		LastDuration:        durationpb.New(stats.LastDuration),
+		TotalRemoved:        stats.TotalRemoved,
+		ConsecutiveFailures: int64(stats.ConsecutiveFailures),
+	}
+	if !stats.LastSweepAt.IsZero() {
+		resp.LastSweepAt = timestamppb.New(stats.LastSweepAt)
+	}
+	if stats.LastError != nil {
+		resp.LastError = stats.LastError.Error()
+	}
+	return resp, nil
+}
+
 // WaitUntilAllEnds waits until all worker tasks finishes.
 func (s *MonitoringService) WaitUntilAllEnds(timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -42,3 +202,85 @@ func (s *MonitoringService) WaitUntilAllEnds(timeout time.Duration) error {
 		time.Sleep(time.Second)
 	}
 }
+
+// InvokerEndpoints handles InvokerEndpoints grpc request.
+// If neither invoker nor invokers wrapped by it implement EndpointReporter, response is returned as not reported.
+func (s *MonitoringService) InvokerEndpoints(ctx context.Context, _ *InvokerEndpointsRequest) (*InvokerEndpointsResponse, error) {
+	if s.balancer == nil {
+		return &InvokerEndpointsResponse{}, nil
+	}
+	now := time.Now()
+	resp := &InvokerEndpointsResponse{
+		Reported: true,
+		Strategy: s.balancer.Strategy().String(),
+	}
+	for _, e := range s.balancer.Endpoints() {
+		ep := &InvokerEndpoint{
+			Url:                 e.URL,
+			Weight:              int64(e.Weight),
+			Healthy:             e.Healthy,
+			Ejected:             e.Ejected(now),
+			InFlight:            int64(e.InFlight),
+			ConsecutiveFailures: int64(e.ConsecutiveFailures),
+			TotalRequests:       e.TotalRequests,
+			TotalFailures:       e.TotalFailures,
+		}
+		if ep.Ejected {
+			ep.EjectedUntil = timestamppb.New(e.EjectedUntil)
+		}
+		if e.LastError != nil {
+			ep.LastError = e.LastError.Error()
+		}
+		if !e.LastCheckedAt.IsZero() {
+			ep.LastCheckedAt = timestamppb.New(e.LastCheckedAt)
+		}
+		resp.Endpoints = append(resp.Endpoints, ep)
+	}
+	return resp, nil
+}
+
+// WorkerStatus handles WorkerStatus grpc request.
+func (s *MonitoringService) WorkerStatus(ctx context.Context, _ *WorkerStatusRequest) (*WorkerStatusResponse, error) {
+	p := s.worker.PanicStats()
+	resp := &WorkerStatusResponse{
+		Panics:             p.Count,
+		LastPanicMessageId: p.LastMessageID,
+		LastPanic:          p.LastValue,
+		Timeouts:           s.worker.Timeouts(),
+	}
+	if !p.LastAt.IsZero() {
+		resp.LastPanicAt = timestamppb.New(p.LastAt)
+	}
+	return resp, nil
+}
+
+func newCanaryResponse(stats CanaryStats) *CanaryResponse {
+	return &CanaryResponse{
+		Reported:       true,
+		Weight:         int64(stats.Weight),
+		StableRequests: stats.StableRequests,
+		StableFailures: stats.StableFailures,
+		CanaryRequests: stats.CanaryRequests,
+		CanaryFailures: stats.CanaryFailures,
+	}
+}
+
+// Canary handles Canary grpc request.
+// If neither invoker nor invokers wrapped by it implement CanaryController, response is returned as not reported.
+func (s *MonitoringService) Canary(ctx context.Context, _ *CanaryRequest) (*CanaryResponse, error) {
+	if s.canary == nil {
+		return &CanaryResponse{}, nil
+	}
+	return newCanaryResponse(s.canary.CanaryStats()), nil
+}
+
+// SetCanaryWeight handles SetCanaryWeight grpc request.
+func (s *MonitoringService) SetCanaryWeight(ctx context.Context, req *SetCanaryWeightRequest) (*CanaryResponse, error) {
+	if s.canary == nil {
+		return nil, status.Error(codes.FailedPrecondition, "invoker doesn't support canary")
+	}
+	if err := s.canary.SetCanaryWeight(int(req.GetWeight())); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return newCanaryResponse(s.canary.CanaryStats()), nil
+}
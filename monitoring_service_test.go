@@ -2,6 +2,7 @@ package sqsd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -9,6 +10,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/taiyoh/sqsd/locker"
+	memorylocker "github.com/taiyoh/sqsd/locker/memory"
+	nooplocker "github.com/taiyoh/sqsd/locker/noop"
 )
 
 func TestMonitoringService(t *testing.T) {
@@ -122,3 +129,184 @@ func TestMonitoringService(t *testing.T) {
 	nextCh <- struct{}{}
 	assert.NoError(t, <-errCh)
 }
+
+type failingLocker struct{}
+
+func (failingLocker) Lock(context.Context, string) error {
+	return errors.New("connection refused")
+}
+
+func (failingLocker) Unlock(context.Context, time.Time) error {
+	return errors.New("connection refused")
+}
+
+func TestMonitoringServiceLockerHealth(t *testing.T) {
+	ctx := context.Background()
+	monitor := NewMonitoringService(nil)
+
+	monitor.locker = memorylocker.New()
+	resp, err := monitor.LockerHealth(ctx, &LockerHealthRequest{})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetReported())
+
+	b, err := locker.NewCircuitBreaker(failingLocker{}, locker.FailureThreshold(1))
+	assert.NoError(t, err)
+	monitor.locker = b
+
+	resp, err = monitor.LockerHealth(ctx, &LockerHealthRequest{})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetReported())
+	assert.Equal(t, CircuitState_CIRCUIT_STATE_CLOSED, resp.GetState())
+	assert.Nil(t, resp.GetOpenedAt())
+
+	assert.Error(t, b.Lock(ctx, "id:1"))
+
+	resp, err = monitor.LockerHealth(ctx, &LockerHealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitState_CIRCUIT_STATE_OPEN, resp.GetState())
+	assert.Equal(t, int64(1), resp.GetConsecutiveFailures())
+	assert.Equal(t, "connection refused", resp.GetLastError())
+	assert.NotNil(t, resp.GetOpenedAt())
+}
+
+func TestMonitoringServiceLocks(t *testing.T) {
+	ctx := context.Background()
+	monitor := NewMonitoringService(nil)
+
+	monitor.locker = nooplocker.Get()
+	_, err := monitor.ListLocks(ctx, &ListLocksRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = monitor.ReleaseLock(ctx, &ReleaseLockRequest{Key: "id:1"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	l := memorylocker.New()
+	monitor.locker = l
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, l.Lock(ctx, fmt.Sprintf("id:%d", i)))
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, req := range []*ListLocksRequest{{Offset: -1}, {Limit: -1}} {
+		_, err = monitor.ListLocks(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	listResp, err := monitor.ListLocks(ctx, &ListLocksRequest{Offset: 1, Limit: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), listResp.GetTotal())
+	if assert.Len(t, listResp.GetLocks(), 2) {
+		assert.Equal(t, "id:2", listResp.GetLocks()[0].GetKey())
+		assert.Equal(t, "id:3", listResp.GetLocks()[1].GetKey())
+	}
+
+	getResp, err := monitor.GetLock(ctx, &GetLockRequest{Key: "id:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "id:1", getResp.GetLock().GetKey())
+	assert.NotNil(t, getResp.GetLock().GetLockedAt())
+
+	_, err = monitor.GetLock(ctx, &GetLockRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = monitor.ReleaseLock(ctx, &ReleaseLockRequest{Key: "id:1"})
+	assert.NoError(t, err)
+	_, err = monitor.GetLock(ctx, &GetLockRequest{Key: "id:1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, l.Lock(ctx, "id:1"))
+}
+
+func TestMonitoringServiceUnlockerStatus(t *testing.T) {
+	ctx := context.Background()
+	monitor := NewMonitoringService(nil)
+
+	resp, err := monitor.UnlockerStatus(ctx, &UnlockerStatusRequest{})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetRunning())
+
+	ml := memorylocker.New()
+	assert.NoError(t, ml.Lock(ctx, "id:1"))
+	u, err := locker.NewUnlocker(ml, 10*time.Millisecond, locker.ExpireDuration(0))
+	assert.NoError(t, err)
+	monitor.unlocker = u
+
+	resp, err = monitor.UnlockerStatus(ctx, &UnlockerStatusRequest{})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetRunning())
+	assert.Nil(t, resp.GetLastSweepAt())
+	assert.Equal(t, int64(-1), resp.GetLastRemoved())
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go u.Run(runCtx)
+
+	assert.Eventually(t, func() bool {
+		resp, err := monitor.UnlockerStatus(ctx, &UnlockerStatusRequest{})
+		return err == nil && resp.GetLastSweepAt() != nil && resp.GetTotalRemoved() == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMonitoringServiceInvokerEndpoints(t *testing.T) {
+	ctx := context.Background()
+	monitor := NewMonitoringService(nil)
+
+	resp, err := monitor.InvokerEndpoints(ctx, &InvokerEndpointsRequest{})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetReported())
+
+	ivk, err := NewBalancedInvoker([]Endpoint{
+		{URL: "http://127.0.0.1:1/jobs", Weight: 3},
+		{URL: "http://127.0.0.1:2/jobs"},
+	}, time.Second, BalancerStrategy(BalanceWeighted), BalancerEjection(1, time.Hour))
+	assert.NoError(t, err)
+	defer ivk.Close()
+	monitor.balancer = ivk
+	assert.Error(t, ivk.Invoke(ctx, Message{ID: "id:1"}))
+
+	resp, err = monitor.InvokerEndpoints(ctx, &InvokerEndpointsRequest{})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetReported())
+	assert.Equal(t, "weighted", resp.GetStrategy())
+	eps := resp.GetEndpoints()
+	assert.Len(t, eps, 2)
+	assert.Equal(t, "http://127.0.0.1:1/jobs", eps[0].GetUrl())
+	assert.Equal(t, int64(3), eps[0].GetWeight())
+	assert.True(t, eps[0].GetHealthy())
+	assert.True(t, eps[0].GetEjected())
+	assert.NotNil(t, eps[0].GetEjectedUntil())
+	assert.Equal(t, int64(1), eps[0].GetTotalFailures())
+	assert.NotEmpty(t, eps[0].GetLastError())
+	assert.False(t, eps[1].GetEjected())
+	assert.Nil(t, eps[1].GetEjectedUntil())
+	assert.Equal(t, int64(1), eps[1].GetWeight())
+}
+
+func TestMonitoringServiceCanary(t *testing.T) {
+	ctx := context.Background()
+	monitor := NewMonitoringService(nil)
+
+	resp, err := monitor.Canary(ctx, &CanaryRequest{})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetReported())
+	_, err = monitor.SetCanaryWeight(ctx, &SetCanaryWeightRequest{Weight: 10})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	ok := InvokerFunc(func(ctx context.Context, q Message) error { return nil })
+	ivk, err := NewCanaryInvoker(ok, ok, 0)
+	assert.NoError(t, err)
+	monitor.canary = ivk
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:1"}))
+
+	resp, err = monitor.SetCanaryWeight(ctx, &SetCanaryWeightRequest{Weight: 100})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetReported())
+	assert.Equal(t, int64(100), resp.GetWeight())
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:2"}))
+
+	resp, err = monitor.Canary(ctx, &CanaryRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), resp.GetWeight())
+	assert.Equal(t, int64(1), resp.GetStableRequests())
+	assert.Equal(t, int64(1), resp.GetCanaryRequests())
+
+	_, err = monitor.SetCanaryWeight(ctx, &SetCanaryWeightRequest{Weight: 101})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
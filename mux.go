@@ -0,0 +1,198 @@
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoHandler is returned by Mux when no handler is registered for type of message.
+var ErrNoHandler = errors.New("no handler for message type")
+
+// Mux is an Invoker which dispatches message to handler registered for its type.
+// As default, type is taken from "Type" message attribute.
+type Mux struct {
+	typeOf   func(Message) (string, bool)
+	notFound Invoker
+
+	mu       sync.RWMutex
+	handlers map[string]Invoker
+}
+
+// MuxOption is an option for Mux.
+type MuxOption func(*Mux)
+
+// MuxTypeAttribute takes type of message from message attribute name.
+func MuxTypeAttribute(name string) MuxOption {
+	return func(m *Mux) {
+		m.typeOf = func(q Message) (string, bool) {
+			v, ok := q.Attributes[name]
+			return v, ok
+		}
+	}
+}
+
+// MuxTypeJSONPath takes type of message from string value at path of JSON payload, such as `$.type`.
+func MuxTypeJSONPath(path string) MuxOption {
+	keys := splitJSONPath(path)
+	return func(m *Mux) {
+		m.typeOf = func(q Message) (string, bool) {
+			var body interface{}
+			if err := json.Unmarshal(q.Payload, &body); err != nil {
+				return "", false
+			}
+			v, ok := lookupJSONPath(body, keys)
+			s, isString := v.(string)
+			return s, ok && isString
+		}
+	}
+}
+
+// MuxNotFound sets invoker for message whose type has no handler. Without it, such message fails with ErrNoHandler.
+func MuxNotFound(ivk Invoker) MuxOption {
+	return func(m *Mux) {
+		m.notFound = ivk
+	}
+}
+
+// NewMux returns Mux instance.
+func NewMux(opts ...MuxOption) *Mux {
+	m := &Mux{handlers: map[string]Invoker{}}
+	MuxTypeAttribute("Type")(m)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Handle registers handler for type. It panics if handler for type is already registered.
+func (m *Mux) Handle(typ string, h Invoker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h == nil {
+		panic("sqsd: nil handler")
+	}
+	if _, ok := m.handlers[typ]; ok {
+		panic("sqsd: multiple registrations for " + typ)
+	}
+	m.handlers[typ] = h
+}
+
+// HandleFunc registers handler function for type.
+func (m *Mux) HandleFunc(typ string, fn func(context.Context, Message) error) {
+	m.Handle(typ, InvokerFunc(fn))
+}
+
+// Invoke calls handler registered for type of message.
+func (m *Mux) Invoke(ctx context.Context, q Message) error {
+	typ, ok := m.typeOf(q)
+	if ok {
+		m.mu.RLock()
+		h, found := m.handlers[typ]
+		m.mu.RUnlock()
+		if found {
+			return h.Invoke(ctx, q)
+		}
+	}
+	if m.notFound != nil {
+		return m.notFound.Invoke(ctx, q)
+	}
+	return fmt.Errorf("%w: %q", ErrNoHandler, typ)
+}
+
+// Validator is implemented by payload type of JSONHandler which validates itself after decoded.
+type Validator interface {
+	Validate() error
+}
+
+// DecodeError is returned by JSONHandler when payload can't be decoded or is invalid.
+// Outcome is the error configured by JSONDecodeOutcome, such as ErrDeadLetter,
+// and errors.Is and errors.As also match it.
+type DecodeError struct {
+	Err     error
+	Outcome error
+}
+
+// Error implements error interface.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode payload: %v", e.Err)
+}
+
+// Unwrap returns cause of failure and outcome.
+func (e *DecodeError) Unwrap() []error {
+	if e.Outcome == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Outcome}
+}
+
+type jsonHandlerParams struct {
+	outcome               error
+	drop                  bool
+	disallowUnknownFields bool
+}
+
+// JSONHandlerOption is an option for JSONHandler.
+type JSONHandlerOption func(*jsonHandlerParams)
+
+// JSONDecodeOutcome sets outcome of message whose payload can't be decoded or is invalid,
+// such as ErrDeadLetter, ErrRetainMessage or &RetryAfterError{}.
+// nil means that message is removed without calling handler.
+// As default, it is treated as failure, so that message is retried after visibility timeout.
+func JSONDecodeOutcome(outcome error) JSONHandlerOption {
+	return func(p *jsonHandlerParams) {
+		p.outcome = outcome
+		p.drop = outcome == nil
+	}
+}
+
+// JSONDisallowUnknownFields treats payload which has unknown fields as decode failure.
+func JSONDisallowUnknownFields() JSONHandlerOption {
+	return func(p *jsonHandlerParams) {
+		p.disallowUnknownFields = true
+	}
+}
+
+// JSONHandler returns Invoker which decodes JSON payload to T and calls fn.
+// If T (or *T) implements Validator, payload is validated after decoded.
+func JSONHandler[T any](fn func(context.Context, T, Message) error, opts ...JSONHandlerOption) Invoker {
+	p := &jsonHandlerParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return InvokerFunc(func(ctx context.Context, q Message) error {
+		v, err := decodeJSONPayload[T](q.Payload, p.disallowUnknownFields)
+		if err != nil {
+			if p.drop {
+				getLogger().Warn("message whose payload is invalid is dropped", "message_id", q.ID, "error", err)
+				return nil
+			}
+			return &DecodeError{Err: err, Outcome: p.outcome}
+		}
+		return fn(ctx, v, q)
+	})
+}
+
+func decodeJSONPayload[T any](payload []byte, disallowUnknownFields bool) (T, error) {
+	var v T
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&v); err != nil {
+		return v, err
+	}
+	if dec.More() {
+		return v, errors.New("unexpected data after JSON payload")
+	}
+	if vv, ok := any(&v).(Validator); ok {
+		return v, vv.Validate()
+	}
+	if vv, ok := any(v).(Validator); ok {
+		return v, vv.Validate()
+	}
+	return v, nil
+}
@@ -0,0 +1,128 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMux(t *testing.T) {
+	ctx := context.Background()
+	var called []string
+	handler := func(name string) func(context.Context, Message) error {
+		return func(ctx context.Context, q Message) error {
+			called = append(called, name+":"+q.ID)
+			return nil
+		}
+	}
+
+	m := NewMux()
+	m.HandleFunc("email", handler("email"))
+	m.HandleFunc("sms", handler("sms"))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "1", Attributes: map[string]string{"Type": "email"}}))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "2", Attributes: map[string]string{"Type": "sms"}}))
+	err := m.Invoke(ctx, Message{ID: "3", Attributes: map[string]string{"Type": "push"}})
+	assert.ErrorIs(t, err, ErrNoHandler)
+	assert.EqualError(t, err, `no handler for message type: "push"`)
+	assert.ErrorIs(t, m.Invoke(ctx, Message{ID: "4"}), ErrNoHandler)
+	assert.Panics(t, func() { m.HandleFunc("email", handler("email")) })
+
+	m = NewMux(MuxTypeJSONPath("$.meta.type"), MuxNotFound(InvokerFunc(handler("not_found"))))
+	m.HandleFunc("email", handler("json_email"))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "5", Payload: []byte(`{"meta":{"type":"email"}}`)}))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "6", Payload: []byte(`{"meta":{"type":1}}`)}))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "7", Payload: []byte(`not json`)}))
+
+	m = NewMux(MuxTypeAttribute("Kind"))
+	m.HandleFunc("email", handler("attr_email"))
+	assert.NoError(t, m.Invoke(ctx, Message{ID: "8", Attributes: map[string]string{"Kind": "email"}}))
+
+	assert.Equal(t, []string{"email:1", "sms:2", "json_email:5", "not_found:6", "not_found:7", "attr_email:8"}, called)
+}
+
+type testEmail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+func (e *testEmail) Validate() error {
+	if e.To == "" {
+		return errors.New("to is required")
+	}
+	return nil
+}
+
+func TestJSONHandler(t *testing.T) {
+	ctx := context.Background()
+	var received []testEmail
+	fn := func(ctx context.Context, e testEmail, q Message) error {
+		received = append(received, e)
+		return nil
+	}
+
+	h := JSONHandler(fn)
+	assert.NoError(t, h.Invoke(ctx, Message{Payload: []byte(`{"to":"a@example.com","subject":"hi","extra":1}`)}))
+	assert.Equal(t, []testEmail{{To: "a@example.com", Subject: "hi"}}, received)
+
+	err := h.Invoke(ctx, Message{Payload: []byte(`{"subject":"hi"}`)})
+	var decodeErr *DecodeError
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.EqualError(t, err, "failed to decode payload: to is required")
+	assert.NotErrorIs(t, err, ErrDeadLetter)
+	assert.Error(t, h.Invoke(ctx, Message{Payload: []byte(`not json`)}))
+	assert.Error(t, h.Invoke(ctx, Message{Payload: []byte(`{"to":"a"} {"to":"b"}`)}))
+
+	h = JSONHandler(fn, JSONDecodeOutcome(ErrDeadLetter), JSONDisallowUnknownFields())
+	err = h.Invoke(ctx, Message{Payload: []byte(`{"to":"a@example.com","extra":1}`)})
+	assert.ErrorIs(t, err, ErrDeadLetter)
+	assert.ErrorAs(t, err, &decodeErr)
+
+	h = JSONHandler(fn, JSONDecodeOutcome(&RetryAfterError{Delay: 10}))
+	var retryErr *RetryAfterError
+	assert.ErrorAs(t, h.Invoke(ctx, Message{Payload: []byte(`[]`)}), &retryErr)
+
+	h = JSONHandler(fn, JSONDecodeOutcome(nil))
+	assert.NoError(t, h.Invoke(ctx, Message{Payload: []byte(`not json`)}))
+	assert.Len(t, received, 1)
+
+	// validation of value receiver.
+	h = JSONHandler(func(ctx context.Context, v testValue, q Message) error { return nil })
+	assert.EqualError(t, h.Invoke(ctx, Message{Payload: []byte(`{}`)}), "failed to decode payload: id is required")
+	assert.NoError(t, h.Invoke(ctx, Message{Payload: []byte(`{"id":1}`)}))
+}
+
+type testValue struct {
+	ID int `json:"id"`
+}
+
+func (v testValue) Validate() error {
+	if v.ID == 0 {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+func TestMuxWithWorker(t *testing.T) {
+	m := NewMux(MuxTypeJSONPath("$.type"))
+	m.Handle("email", JSONHandler(func(ctx context.Context, e testEmail, q Message) error {
+		return nil
+	}, JSONDecodeOutcome(ErrDeadLetter)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	rm := &testRemover{
+		removed:     make(chan string, 10),
+		retried:     make(chan time.Duration, 10),
+		deadLetters: make(chan string, 10),
+	}
+	broker := make(chan Message, 1)
+	startWorker(ctx, m, broker, rm)
+
+	broker <- Message{ID: "ok", Payload: []byte(`{"type":"email","to":"a@example.com"}`)}
+	assert.Equal(t, "ok", <-rm.removed)
+	broker <- Message{ID: "invalid", Payload: []byte(`{"type":"email"}`)}
+	assert.Equal(t, "invalid", <-rm.deadLetters)
+}
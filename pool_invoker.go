@@ -0,0 +1,284 @@
+package sqsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// PoolInvoker invokes long-lived worker processes by newline delimited JSON via stdin and stdout.
+//
+// Each line of request written to stdin is:
+//
+//	{"id":"<message id>","payload":"<message body>","attributes":{"key":"value"},"idempotency_key":"<dedup key>","deadline":"<RFC3339>"}
+//
+// and worker process must write response line to stdout:
+//
+//	{"id":"<message id>","status":"ok"}
+//
+// status is one of "ok", "retain" and "error", and "error" can have "message" field.
+// stdout lines which are not JSON are written to log. stderr is written to log too.
+// Worker process which exits or fails to respond in time is restarted on next invocation.
+type PoolInvoker struct {
+	name    string
+	args    []string
+	timeout time.Duration
+	maxJobs int
+	env     []string
+	idle    chan *poolProcess
+	wg      sync.WaitGroup
+}
+
+// PoolInvokerOption is an option for PoolInvoker.
+type PoolInvokerOption func(*PoolInvoker)
+
+// PoolMaxJobs sets count of jobs after which worker process is recycled.
+// 0 means worker process is never recycled.
+func PoolMaxJobs(n int) PoolInvokerOption {
+	return func(ivk *PoolInvoker) {
+		ivk.maxJobs = n
+	}
+}
+
+// PoolEnv adds environment variables such as "KEY=value" to worker processes.
+func PoolEnv(env ...string) PoolInvokerOption {
+	return func(ivk *PoolInvoker) {
+		ivk.env = append(ivk.env, env...)
+	}
+}
+
+// NewPoolInvoker returns PoolInvoker instance which has size worker processes.
+// size should be the same as parallel count of consumer.
+// Worker processes are started lazily on first invocation.
+func NewPoolInvoker(command []string, size int, dur time.Duration, opts ...PoolInvokerOption) (*PoolInvoker, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("command is required")
+	}
+	if size <= 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	ivk := &PoolInvoker{
+		name:    command[0],
+		args:    command[1:],
+		timeout: dur,
+		idle:    make(chan *poolProcess, size),
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	for i := 0; i < size; i++ {
+		ivk.idle <- &poolProcess{index: i}
+	}
+	return ivk, nil
+}
+
+type poolRequest struct {
+	ID             string            `json:"id"`
+	Payload        string            `json:"payload"`
+	Attributes     map[string]string `json:"attributes,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	Deadline       string            `json:"deadline,omitempty"`
+}
+
+type poolResponse struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// poolProcess is a slot of worker process. cmd is nil until it starts.
+type poolProcess struct {
+	index     int
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan poolResponse
+	done      chan struct{}
+	jobs      int
+}
+
+func (p *poolProcess) running() bool {
+	if p.cmd == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Invoke sends message to idle worker process and waits for its response.
+func (ivk *PoolInvoker) Invoke(ctx context.Context, q Message) error {
+	var p *poolProcess
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case p = <-ivk.idle:
+	}
+	defer func() { ivk.idle <- p }()
+
+	logger := getLogger().With("message_id", q.ID, "worker", p.index)
+	if !p.running() {
+		if err := ivk.start(p); err != nil {
+			return err
+		}
+		logger.Info("worker process started", "pid", p.cmd.Process.Pid)
+	}
+
+	ctx, cancel := withInvocationTimeout(ctx, ivk.timeout)
+	defer cancel()
+
+	deadline, _ := formatDeadline(ctx)
+	payload, attrs := jsonPayload(q)
+	b, err := json.Marshal(poolRequest{
+		ID:             q.ID,
+		Payload:        payload,
+		Attributes:     attrs,
+		IdempotencyKey: q.DedupKey,
+		Deadline:       deadline,
+	})
+	if err != nil {
+		return err
+	}
+	// write is done in goroutine because it blocks while worker process doesn't read stdin.
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(append(b, '\n'))
+		written <- err
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Info("worker process is killed by timeout")
+		ivk.kill(p)
+		<-written
+		return fmt.Errorf("worker process timeout: %w", ctx.Err())
+	case err := <-written:
+		if err != nil {
+			ivk.kill(p)
+			return fmt.Errorf("failed to write request to worker process: %w", err)
+		}
+	}
+
+	var resp poolResponse
+	select {
+	case <-ctx.Done():
+		logger.Info("worker process is killed by timeout")
+		ivk.kill(p)
+		return fmt.Errorf("worker process timeout: %w", ctx.Err())
+	case r, ok := <-p.responses:
+		if !ok {
+			ivk.kill(p)
+			return errors.New("worker process exited")
+		}
+		resp = r
+	}
+	if resp.ID != q.ID {
+		ivk.kill(p)
+		return fmt.Errorf("unexpected response id: %s", resp.ID)
+	}
+
+	p.jobs++
+	if ivk.maxJobs > 0 && p.jobs >= ivk.maxJobs {
+		logger.Info("worker process is recycled", "jobs", p.jobs)
+		ivk.stop(p)
+	}
+
+	switch resp.Status {
+	case "ok":
+		return nil
+	case "retain":
+		return ErrRetainMessage
+	case "error":
+		return fmt.Errorf("worker error: %s", resp.Message)
+	}
+	return fmt.Errorf("unknown response status: %s", resp.Status)
+}
+
+func (ivk *PoolInvoker) start(p *poolProcess) error {
+	logger := getLogger().With("worker", p.index)
+	cmd := exec.Command(ivk.name, ivk.args...)
+	cmd.Env = append(os.Environ(), ivk.env...)
+	cmd.Stderr = &logWriter{logger: logger, stream: "stderr"}
+	setProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd = cmd
+	p.stdin = stdin
+	p.responses = make(chan poolResponse)
+	p.done = make(chan struct{})
+	p.jobs = 0
+
+	ivk.wg.Add(1)
+	go func(responses chan poolResponse, done chan struct{}) {
+		defer ivk.wg.Done()
+		defer close(done)
+		defer close(responses)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize*16)
+		for scanner.Scan() {
+			var resp poolResponse
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil || resp.ID == "" {
+				logger.Info("command output", "stream", "stdout", "line", scanner.Text())
+				continue
+			}
+			responses <- resp
+		}
+		err := cmd.Wait()
+		logger.Info("worker process exited", "error", err)
+	}(p.responses, p.done)
+
+	return nil
+}
+
+// stop closes stdin of worker process and kills it if it doesn't exit in a second.
+func (ivk *PoolInvoker) stop(p *poolProcess) {
+	if p.cmd == nil {
+		return
+	}
+	_ = p.stdin.Close()
+	t := time.NewTimer(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.responses:
+			// discard late responses until worker process exits.
+		case <-t.C:
+			_ = killProcessGroup(p.cmd)
+		}
+	}
+}
+
+// kill kills worker process immediately and waits until it exits.
+func (ivk *PoolInvoker) kill(p *poolProcess) {
+	_ = killProcessGroup(p.cmd)
+	ivk.stop(p)
+}
+
+// Close stops all worker processes. Invoke must not be called after Close.
+func (ivk *PoolInvoker) Close() error {
+	for i := 0; i < cap(ivk.idle); i++ {
+		ivk.stop(<-ivk.idle)
+	}
+	ivk.wg.Wait()
+	return nil
+}
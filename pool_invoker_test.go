@@ -0,0 +1,93 @@
+//go:build unix
+
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// poolTestWorker responds by message id, and prints its pid as message for "pid".
+const poolTestWorker = `
+while read -r line; do
+  id=$(echo "$line" | sed -e 's/.*"id":"\([^"]*\)".*/\1/')
+  case "$id" in
+    retain) echo "{\"id\":\"$id\",\"status\":\"retain\"}" ;;
+    fail) echo "{\"id\":\"$id\",\"status\":\"error\",\"message\":\"boom\"}" ;;
+    crash) exit 1 ;;
+    sleep) sleep 10 ;;
+    pid-*) echo "not json output"; echo "{\"id\":\"$id\",\"status\":\"error\",\"message\":\"$$\"}" ;;
+    *) echo "{\"id\":\"$id\",\"status\":\"ok\"}" ;;
+  esac
+done
+`
+
+func TestPoolInvoker(t *testing.T) {
+	_, err := NewPoolInvoker(nil, 1, time.Second)
+	assert.EqualError(t, err, "command is required")
+	_, err = NewPoolInvoker([]string{"sh"}, 0, time.Second)
+	assert.EqualError(t, err, "size must be greater than 0")
+
+	ctx := context.Background()
+	ivk, err := NewPoolInvoker([]string{"sh", "-c", poolTestWorker}, 1, 300*time.Millisecond, PoolMaxJobs(3))
+	assert.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, ivk.Close()) })
+
+	pid := func(n int) string {
+		err := ivk.Invoke(ctx, Message{ID: fmt.Sprintf("pid-%d", n)})
+		assert.Error(t, err)
+		return err.Error()
+	}
+
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:1", Payload: []byte(`{"foo":"bar"}`)}))
+	assert.ErrorIs(t, ivk.Invoke(ctx, Message{ID: "retain"}), ErrRetainMessage)
+	// third job recycles worker process.
+	pid1 := pid(1)
+	pid2 := pid(2)
+	assert.NotEqual(t, pid1, pid2)
+	assert.Equal(t, pid2, pid(3))
+
+	assert.EqualError(t, ivk.Invoke(ctx, Message{ID: "fail"}), "worker error: boom")
+	assert.EqualError(t, ivk.Invoke(ctx, Message{ID: "crash"}), "worker process exited")
+	pid3 := pid(4)
+	assert.NotEqual(t, pid2, pid3)
+
+	started := time.Now()
+	assert.EqualError(t, ivk.Invoke(ctx, Message{ID: "sleep"}), "worker process timeout: context deadline exceeded")
+	assert.Less(t, time.Since(started), 2*time.Second)
+	assert.NotEqual(t, pid3, pid(5))
+}
+
+func TestPoolInvokerParallel(t *testing.T) {
+	ivk, err := NewPoolInvoker([]string{"sh", "-c", poolTestWorker}, 3, time.Second)
+	assert.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, ivk.Close()) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: fmt.Sprintf("id:%d", i)}))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPoolInvokerWriteTimeout(t *testing.T) {
+	// worker process never reads stdin, so that writing large payload blocks.
+	ivk, err := NewPoolInvoker([]string{"sh", "-c", "sleep 10"}, 1, 300*time.Millisecond)
+	assert.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, ivk.Close()) })
+
+	started := time.Now()
+	err = ivk.Invoke(context.Background(), Message{ID: "id:1", Payload: bytes.Repeat([]byte("a"), 1<<20)})
+	assert.EqualError(t, err, "worker process timeout: context deadline exceeded")
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
@@ -0,0 +1,52 @@
+package sqsd
+
+import (
+	"context"
+	"sync"
+)
+
+// maxResponseSize is max size of response body which is sent to reply queue, the same as max message size of SQS.
+const maxResponseSize = 256 * 1024
+
+type responseRecorderKey struct{}
+
+// responseRecorder keeps response body and follow-up messages of worker in context of invocation.
+type responseRecorder struct {
+	mu        sync.Mutex
+	body      []byte
+	followUps []FollowUpMessage
+}
+
+func withResponseRecorder(ctx context.Context) (context.Context, *responseRecorder) {
+	rec := &responseRecorder{}
+	return context.WithValue(ctx, responseRecorderKey{}, rec), rec
+}
+
+func responseRecorderFrom(ctx context.Context) *responseRecorder {
+	rec, _ := ctx.Value(responseRecorderKey{}).(*responseRecorder)
+	return rec
+}
+
+func (r *responseRecorder) Body() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body
+}
+
+func (r *responseRecorder) FollowUps() []FollowUpMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.followUps
+}
+
+// SetResponse sets response body of worker to context of invocation, which is sent to reply queue.
+// Invoker implemented by library user can call it to reply. It does nothing if ctx is not for invocation.
+func SetResponse(ctx context.Context, body []byte) {
+	rec := responseRecorderFrom(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.body = body
+}
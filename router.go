@@ -0,0 +1,212 @@
+package sqsd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrNoRoute is returned by RoutingInvoker when no route matches message and default route is not set.
+var ErrNoRoute = errors.New("no route matches message")
+
+// RouteMatcher reports whether message should be sent to route.
+type RouteMatcher func(Message) bool
+
+// MatchAttribute matches message whose attribute name has value.
+func MatchAttribute(name, value string) RouteMatcher {
+	return func(q Message) bool {
+		v, ok := q.Attributes[name]
+		return ok && v == value
+	}
+}
+
+// MatchSNSSubject matches message which is SNS notification with subject.
+func MatchSNSSubject(subject string) RouteMatcher {
+	return func(q Message) bool {
+		var n struct {
+			Type    string
+			Subject string
+		}
+		if err := json.Unmarshal(q.Payload, &n); err != nil {
+			return false
+		}
+		return n.Type == "Notification" && n.Subject == subject
+	}
+}
+
+// MatchJSONPath matches message whose JSON payload has value at path.
+// path is the same format as DedupByJSONPath, and value is compared after decoded as JSON.
+func MatchJSONPath(path string, value any) RouteMatcher {
+	keys := splitJSONPath(path)
+	// normalize value to the same types as decoded JSON, such as float64 for numbers.
+	if b, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(b, &value)
+	}
+	return func(q Message) bool {
+		var body interface{}
+		if err := json.Unmarshal(q.Payload, &body); err != nil {
+			return false
+		}
+		v, ok := lookupJSONPath(body, keys)
+		return ok && reflect.DeepEqual(v, value)
+	}
+}
+
+// ParseRouteRule returns RouteMatcher by rule such as:
+//
+//   - `$.type == "email"`: value at JSON path of payload. value is JSON literal.
+//   - `attribute:Type == "email"`: message attribute.
+//   - `subject == "email"`: subject of SNS notification.
+func ParseRouteRule(rule string) (RouteMatcher, error) {
+	lhs, rhs, ok := strings.Cut(rule, "==")
+	if !ok {
+		return nil, fmt.Errorf("invalid route rule: %s", rule)
+	}
+	lhs, rhs = strings.TrimSpace(lhs), strings.TrimSpace(rhs)
+	var value any
+	if err := json.Unmarshal([]byte(rhs), &value); err != nil {
+		return nil, fmt.Errorf("invalid value of route rule: %s", rule)
+	}
+	if strings.HasPrefix(lhs, "$") {
+		return MatchJSONPath(lhs, value), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("value must be string: %s", rule)
+	}
+	if name, ok := strings.CutPrefix(lhs, "attribute:"); ok && name != "" {
+		return MatchAttribute(name, s), nil
+	}
+	if lhs == "subject" {
+		return MatchSNSSubject(s), nil
+	}
+	return nil, fmt.Errorf("unknown selector of route rule: %s", rule)
+}
+
+// Route is a destination of RoutingInvoker.
+// Concurrency limits count of concurrent invocations of this route, and 0 means unlimited.
+// Message which exceeds it doesn't wait, and is retried a second later.
+// Timeout shortens deadline of invocation of this route, and 0 means timeout of invoker.
+type Route struct {
+	Name        string
+	Match       RouteMatcher
+	Invoker     Invoker
+	Concurrency int
+	Timeout     time.Duration
+}
+
+type routeEntry struct {
+	Route
+	// invoker is Invoker of route limited by Concurrency and Timeout.
+	invoker Invoker
+}
+
+func newRouteEntry(route Route) *routeEntry {
+	e := &routeEntry{Route: route, invoker: route.Invoker}
+	if route.Timeout > 0 {
+		e.invoker = Timeout(route.Timeout)(e.invoker)
+	}
+	if route.Concurrency > 0 {
+		e.invoker = routeLimit(route.Concurrency)(e.invoker)
+	}
+	return e
+}
+
+// routeBusyDelay is delay of message whose route runs as many invocations as its Concurrency.
+const routeBusyDelay = time.Second
+
+// routeLimit limits count of concurrent invocations to n. Unlike ConcurrencyLimit, invocation which exceeds it
+// returns RetryAfterError immediately, so that worker is not blocked and processes messages of other routes.
+func routeLimit(n int) Middleware {
+	sem := semaphore.NewWeighted(int64(n))
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			if !sem.TryAcquire(1) {
+				return &RetryAfterError{Delay: routeBusyDelay}
+			}
+			defer sem.Release(1)
+			return next.Invoke(ctx, q)
+		})
+	}
+}
+
+// RoutingInvoker sends message to invoker of the first route which matches it.
+type RoutingInvoker struct {
+	routes   []*routeEntry
+	fallback *Route
+}
+
+// RoutingInvokerOption is an option for RoutingInvoker.
+type RoutingInvokerOption func(*RoutingInvoker)
+
+// RouterDefault sets route for message which matches no route.
+// Without it, such message fails with ErrNoRoute.
+func RouterDefault(ivk Invoker, concurrency int) RoutingInvokerOption {
+	return func(r *RoutingInvoker) {
+		r.fallback = &Route{Name: "default", Invoker: ivk, Concurrency: concurrency}
+	}
+}
+
+// NewRoutingInvoker returns RoutingInvoker instance.
+func NewRoutingInvoker(routes []Route, opts ...RoutingInvokerOption) (*RoutingInvoker, error) {
+	r := &RoutingInvoker{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for i, route := range routes {
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route%d", i)
+		}
+		if route.Match == nil || route.Invoker == nil {
+			return nil, fmt.Errorf("matcher and invoker are required: %s", route.Name)
+		}
+		r.routes = append(r.routes, newRouteEntry(route))
+	}
+	if r.fallback != nil {
+		if r.fallback.Invoker == nil {
+			return nil, errors.New("invoker of default route is required")
+		}
+		r.routes = append(r.routes, newRouteEntry(*r.fallback))
+	}
+	return r, nil
+}
+
+// Invoke sends message to invoker of matched route.
+func (r *RoutingInvoker) Invoke(ctx context.Context, q Message) error {
+	route := r.route(q)
+	if route == nil {
+		return ErrNoRoute
+	}
+	getLogger().Debug("message is routed", "message_id", q.ID, "route", route.Name)
+	return route.invoker.Invoke(ctx, q)
+}
+
+// route returns the first matched route. default route is the last one and has no matcher.
+func (r *RoutingInvoker) route(q Message) *routeEntry {
+	for _, e := range r.routes {
+		if e.Match == nil || e.Match(q) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Unwrap returns invokers of routes in the order of matching.
+func (r *RoutingInvoker) Unwrap() []Invoker {
+	ivks := make([]Invoker, 0, len(r.routes))
+	for _, route := range r.routes {
+		ivks = append(ivks, route.Invoker)
+	}
+	return ivks
+}
+
+// Close closes invokers of routes which implement io.Closer.
+func (r *RoutingInvoker) Close() error {
+	return closeInvokers(r.Unwrap()...)
+}
@@ -0,0 +1,146 @@
+package sqsd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRouteRule(t *testing.T) {
+	for _, tt := range []struct {
+		rule  string
+		msg   Message
+		match bool
+	}{
+		{`$.type == "email"`, Message{Payload: []byte(`{"type":"email"}`)}, true},
+		{`$.type == "email"`, Message{Payload: []byte(`{"type":"sms"}`)}, false},
+		{`$.type == "email"`, Message{Payload: []byte(`not json`)}, false},
+		{`$.job.priority == 1`, Message{Payload: []byte(`{"job":{"priority":1}}`)}, true},
+		{`$.items.0.urgent==true`, Message{Payload: []byte(`{"items":[{"urgent":true}]}`)}, true},
+		{`attribute:Type == "report"`, Message{Attributes: map[string]string{"Type": "report"}}, true},
+		{`attribute:Type == "report"`, Message{}, false},
+		{`subject == "order.created"`, Message{Payload: []byte(`{"Type":"Notification","Subject":"order.created","Message":"{}"}`)}, true},
+		{`subject == "order.created"`, Message{Payload: []byte(`{"Subject":"order.created"}`)}, false},
+	} {
+		m, err := ParseRouteRule(tt.rule)
+		assert.NoError(t, err, tt.rule)
+		assert.Equal(t, tt.match, m(tt.msg), tt.rule)
+	}
+
+	for _, rule := range []string{
+		`$.type = "email"`,
+		`$.type == email`,
+		`attribute:Type == 1`,
+		`attribute: == "x"`,
+		`header == "x"`,
+	} {
+		_, err := ParseRouteRule(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestRoutingInvoker(t *testing.T) {
+	var mu sync.Mutex
+	var called []string
+	target := func(name string) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			called = append(called, name+":"+q.ID)
+			return nil
+		})
+	}
+	routes := []Route{
+		{Name: "email", Match: MatchJSONPath("$.type", "email"), Invoker: target("email")},
+		{Name: "report", Match: MatchAttribute("Type", "report"), Invoker: target("report")},
+	}
+	ctx := context.Background()
+
+	r, err := NewRoutingInvoker(routes)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Invoke(ctx, Message{ID: "1", Payload: []byte(`{"type":"email"}`)}))
+	assert.NoError(t, r.Invoke(ctx, Message{ID: "2", Payload: []byte(`{"type":"email"}`), Attributes: map[string]string{"Type": "report"}}))
+	assert.NoError(t, r.Invoke(ctx, Message{ID: "3", Attributes: map[string]string{"Type": "report"}}))
+	assert.ErrorIs(t, r.Invoke(ctx, Message{ID: "4"}), ErrNoRoute)
+
+	r, err = NewRoutingInvoker(routes, RouterDefault(target("default"), 0))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Invoke(ctx, Message{ID: "4"}))
+	assert.Equal(t, []string{"email:1", "email:2", "report:3", "default:4"}, called)
+
+	_, err = NewRoutingInvoker([]Route{{Name: "broken", Invoker: target("broken")}})
+	assert.EqualError(t, err, "matcher and invoker are required: broken")
+}
+
+func TestRoutingInvokerConcurrency(t *testing.T) {
+	started := make(chan struct{}, 3)
+	unblock := make(chan struct{})
+	slow := InvokerFunc(func(ctx context.Context, q Message) error {
+		started <- struct{}{}
+		<-unblock
+		return nil
+	})
+	fast := InvokerFunc(func(ctx context.Context, q Message) error { return nil })
+	r, err := NewRoutingInvoker([]Route{
+		{Name: "slow", Match: MatchAttribute("Type", "slow"), Invoker: slow, Concurrency: 2},
+		{Name: "fast", Match: MatchAttribute("Type", "fast"), Invoker: fast, Concurrency: 1},
+	})
+	assert.NoError(t, err)
+	slowMsg := Message{Attributes: map[string]string{"Type": "slow"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, r.Invoke(context.Background(), slowMsg))
+		}()
+		<-started
+	}
+
+	// busy route doesn't block, and other route is still invoked.
+	var retryErr *RetryAfterError
+	assert.ErrorAs(t, r.Invoke(context.Background(), slowMsg), &retryErr)
+	assert.Equal(t, time.Second, retryErr.Delay)
+	assert.NoError(t, r.Invoke(context.Background(), Message{Attributes: map[string]string{"Type": "fast"}}))
+
+	close(unblock)
+	wg.Wait()
+	assert.NoError(t, r.Invoke(context.Background(), slowMsg))
+}
+
+func TestRoutingInvokerTimeout(t *testing.T) {
+	ivk := InvokerFunc(func(ctx context.Context, q Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r, err := NewRoutingInvoker([]Route{
+		{Name: "notification", Match: MatchAttribute("Type", "notification"), Invoker: ivk, Timeout: 10 * time.Millisecond},
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, r.Invoke(context.Background(), Message{Attributes: map[string]string{"Type": "notification"}}), context.DeadlineExceeded)
+}
+
+type closableInvoker struct {
+	InvokerFunc
+	closed bool
+}
+
+func (i *closableInvoker) Close() error {
+	i.closed = true
+	return nil
+}
+
+func TestRoutingInvokerClose(t *testing.T) {
+	a, b := &closableInvoker{}, &closableInvoker{}
+	r, err := NewRoutingInvoker([]Route{
+		{Match: MatchAttribute("Type", "a"), Invoker: a, Concurrency: 1},
+	}, RouterDefault(b, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.True(t, a.closed)
+	assert.True(t, b.closed)
+}
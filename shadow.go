@@ -0,0 +1,183 @@
+package sqsd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// outcomeOf returns name of invocation result, which is used to compare results of invokers.
+func outcomeOf(err error) string {
+	var retryErr *RetryAfterError
+	var panicErr *PanicError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrRetainMessage):
+		return "retain"
+	case errors.As(err, &retryErr):
+		return "retry_after"
+	case errors.Is(err, ErrDeadLetter):
+		return "dead_letter"
+	case errors.As(err, &panicErr):
+		return "panic"
+	case isTimeout(err):
+		return "timeout"
+	}
+	return "failure"
+}
+
+// ShadowInvoker mirrors messages to shadow invoker in addition to primary invoker.
+// Result of invocation is taken only from primary. Shadow is invoked in background (fire-and-forget),
+// and its outcome and response body are compared with primary's and logged.
+// Message is not mirrored when shadow invocations run as many as concurrency.
+type ShadowInvoker struct {
+	primary     Invoker
+	shadow      Invoker
+	rate        float64
+	timeout     time.Duration
+	concurrency int
+
+	wg         sync.WaitGroup
+	sem        *semaphore.Weighted
+	mirrored   atomic.Int64
+	mismatched atomic.Int64
+	dropped    atomic.Int64
+}
+
+// ShadowInvokerOption is an option for ShadowInvoker.
+type ShadowInvokerOption func(*ShadowInvoker)
+
+// ShadowRate sets ratio of messages mirrored to shadow, from 0 to 1. As default, all messages are mirrored.
+func ShadowRate(rate float64) ShadowInvokerOption {
+	return func(ivk *ShadowInvoker) {
+		ivk.rate = rate
+	}
+}
+
+// ShadowTimeout sets timeout of shadow invocation. As default, it is 1 minute.
+func ShadowTimeout(d time.Duration) ShadowInvokerOption {
+	return func(ivk *ShadowInvoker) {
+		ivk.timeout = d
+	}
+}
+
+// ShadowConcurrency sets max count of shadow invocations running in background. As default, it is 100.
+func ShadowConcurrency(n int) ShadowInvokerOption {
+	return func(ivk *ShadowInvoker) {
+		ivk.concurrency = n
+	}
+}
+
+// NewShadowInvoker returns ShadowInvoker instance.
+func NewShadowInvoker(primary, shadow Invoker, opts ...ShadowInvokerOption) (*ShadowInvoker, error) {
+	if primary == nil || shadow == nil {
+		return nil, errors.New("primary and shadow invokers are required")
+	}
+	ivk := &ShadowInvoker{
+		primary:     primary,
+		shadow:      shadow,
+		rate:        1,
+		timeout:     time.Minute,
+		concurrency: 100,
+	}
+	for _, opt := range opts {
+		opt(ivk)
+	}
+	if ivk.rate < 0 || ivk.rate > 1 {
+		return nil, errors.New("shadow rate must be between 0 and 1")
+	}
+	if ivk.concurrency <= 0 {
+		return nil, errors.New("shadow concurrency must be greater than 0")
+	}
+	ivk.sem = semaphore.NewWeighted(int64(ivk.concurrency))
+	return ivk, nil
+}
+
+type shadowResult struct {
+	err  error
+	body []byte
+}
+
+// Invoke invokes primary, and shadow in background if message is selected to mirror.
+func (ivk *ShadowInvoker) Invoke(ctx context.Context, q Message) error {
+	if ivk.rate == 0 || (ivk.rate < 1 && rand.Float64() >= ivk.rate) {
+		return ivk.primary.Invoke(ctx, q)
+	}
+	if !ivk.sem.TryAcquire(1) {
+		ivk.dropped.Add(1)
+		getLogger().Debug("shadow invocation is dropped because too many shadow invocations are running", "message_id", q.ID)
+		return ivk.primary.Invoke(ctx, q)
+	}
+	primaryCh := make(chan shadowResult, 1)
+	ivk.wg.Add(1)
+	go ivk.invokeShadow(q, primaryCh)
+
+	// panic of primary is returned as PanicError, so that shadow always receives result of primary.
+	err := recoverPanic(func() error {
+		return ivk.primary.Invoke(ctx, q)
+	})
+	res := shadowResult{err: err}
+	if rec := responseRecorderFrom(ctx); rec != nil {
+		res.body = rec.Body()
+	}
+	primaryCh <- res
+	return err
+}
+
+// invokeShadow invokes shadow by its own context, so that its response and follow-ups never affect primary.
+func (ivk *ShadowInvoker) invokeShadow(q Message, primaryCh <-chan shadowResult) {
+	defer ivk.wg.Done()
+	defer ivk.sem.Release(1)
+	ctx, cancel := context.WithTimeout(context.Background(), ivk.timeout)
+	defer cancel()
+	ctx, rec := withResponseRecorder(ctx)
+
+	started := time.Now()
+	err := recoverPanic(func() error {
+		return ivk.shadow.Invoke(ctx, q)
+	})
+	elapsed := time.Since(started).String()
+	primary := <-primaryCh
+	ivk.mirrored.Add(1)
+
+	logger := getLogger().With("message_id", q.ID, "elapsed", elapsed)
+	primaryOutcome, shadowOutcome := outcomeOf(primary.err), outcomeOf(err)
+	if primaryOutcome == shadowOutcome && bytes.Equal(primary.body, rec.Body()) {
+		logger.Debug("shadow result matches primary", "outcome", shadowOutcome)
+		return
+	}
+	ivk.mismatched.Add(1)
+	logger.Warn("shadow result differs from primary",
+		"primary_outcome", primaryOutcome,
+		"shadow_outcome", shadowOutcome,
+		"shadow_error", err,
+		"body_matched", bytes.Equal(primary.body, rec.Body()))
+}
+
+// ShadowStats returns count of mirrored messages and count of them whose results differ from primary.
+func (ivk *ShadowInvoker) ShadowStats() (mirrored, mismatched int64) {
+	return ivk.mirrored.Load(), ivk.mismatched.Load()
+}
+
+// ShadowDropped returns count of messages which are not mirrored because shadow invocations are full.
+func (ivk *ShadowInvoker) ShadowDropped() int64 {
+	return ivk.dropped.Load()
+}
+
+// Unwrap returns primary and shadow invokers.
+func (ivk *ShadowInvoker) Unwrap() []Invoker {
+	return []Invoker{ivk.primary, ivk.shadow}
+}
+
+// Close waits until shadow invocations in background end, and closes primary and shadow invokers which implement io.Closer.
+func (ivk *ShadowInvoker) Close() error {
+	ivk.wg.Wait()
+	return closeInvokers(ivk.primary, ivk.shadow)
+}
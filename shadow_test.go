@@ -0,0 +1,132 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowInvoker(t *testing.T) {
+	primary := InvokerFunc(func(ctx context.Context, q Message) error {
+		SetResponse(ctx, []byte(`{"id":"`+q.ID+`"}`))
+		if q.ID == "primary_failure" {
+			return errors.New("primary failure")
+		}
+		return nil
+	})
+	var shadowCalls atomic.Int32
+	shadow := InvokerFunc(func(ctx context.Context, q Message) error {
+		shadowCalls.Add(1)
+		SetResponse(ctx, []byte(`{"id":"`+q.ID+`"}`))
+		AddFollowUp(ctx, FollowUpMessage{QueueURL: "http://localhost/queue/next"})
+		switch q.ID {
+		case "shadow_failure":
+			return errors.New("shadow failure")
+		case "shadow_panic":
+			panic("boom")
+		}
+		return nil
+	})
+	ivk, err := NewShadowInvoker(primary, shadow)
+	assert.NoError(t, err)
+
+	ctx, rec := withResponseRecorder(context.Background())
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "ok"}))
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "shadow_failure"}), "result is taken only from primary")
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "shadow_panic"}))
+	assert.EqualError(t, ivk.Invoke(ctx, Message{ID: "primary_failure"}), "primary failure")
+	assert.NoError(t, ivk.Close())
+
+	assert.Equal(t, int32(4), shadowCalls.Load())
+	assert.Empty(t, rec.FollowUps(), "follow-ups of shadow are not sent")
+	assert.Equal(t, `{"id":"primary_failure"}`, string(rec.Body()))
+	mirrored, mismatched := ivk.ShadowStats()
+	assert.Equal(t, int64(4), mirrored)
+	assert.Equal(t, int64(3), mismatched)
+
+	ivk, err = NewShadowInvoker(primary, shadow, ShadowRate(0))
+	assert.NoError(t, err)
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "ok"}))
+	assert.NoError(t, ivk.Close())
+	assert.Equal(t, int32(4), shadowCalls.Load())
+
+	_, err = NewShadowInvoker(primary, shadow, ShadowRate(1.5))
+	assert.Error(t, err)
+	_, err = NewShadowInvoker(primary, nil)
+	assert.Error(t, err)
+}
+
+func TestShadowInvokerBodyMismatch(t *testing.T) {
+	primary := InvokerFunc(func(ctx context.Context, q Message) error {
+		SetResponse(ctx, []byte(`v1`))
+		return nil
+	})
+	shadow := InvokerFunc(func(ctx context.Context, q Message) error {
+		SetResponse(ctx, []byte(`v2`))
+		return nil
+	})
+	ivk, err := NewShadowInvoker(primary, shadow)
+	assert.NoError(t, err)
+	ctx, _ := withResponseRecorder(context.Background())
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:1"}))
+	assert.NoError(t, ivk.Close())
+	_, mismatched := ivk.ShadowStats()
+	assert.Equal(t, int64(1), mismatched)
+}
+
+func TestShadowInvokerPrimaryPanic(t *testing.T) {
+	primary := InvokerFunc(func(ctx context.Context, q Message) error {
+		panic("boom")
+	})
+	shadow := InvokerFunc(func(ctx context.Context, q Message) error {
+		return nil
+	})
+	ivk, err := NewShadowInvoker(primary, shadow)
+	assert.NoError(t, err)
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}), &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+
+	closed := make(chan error, 1)
+	go func() { closed <- ivk.Close() }()
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("shadow invocation doesn't end after primary panics")
+	}
+	mirrored, mismatched := ivk.ShadowStats()
+	assert.Equal(t, int64(1), mirrored)
+	assert.Equal(t, int64(1), mismatched)
+}
+
+func TestShadowInvokerConcurrency(t *testing.T) {
+	primary := InvokerFunc(func(ctx context.Context, q Message) error { return nil })
+	unblock := make(chan struct{})
+	var shadowCalls atomic.Int32
+	shadow := InvokerFunc(func(ctx context.Context, q Message) error {
+		shadowCalls.Add(1)
+		<-unblock
+		return nil
+	})
+	ivk, err := NewShadowInvoker(primary, shadow, ShadowConcurrency(1))
+	assert.NoError(t, err)
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}))
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:2"}), "primary is invoked even if shadow is dropped")
+	close(unblock)
+	assert.NoError(t, ivk.Close())
+
+	assert.Equal(t, int32(1), shadowCalls.Load())
+	mirrored, _ := ivk.ShadowStats()
+	assert.Equal(t, int64(1), mirrored)
+	assert.Equal(t, int64(1), ivk.ShadowDropped())
+
+	_, err = NewShadowInvoker(primary, shadow, ShadowConcurrency(0))
+	assert.EqualError(t, err, "shadow concurrency must be greater than 0")
+}
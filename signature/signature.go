@@ -0,0 +1,144 @@
+// Package signature signs and verifies requests which are sent to worker by sqsd.
+//
+// sqsd sends two headers with request:
+//
+//	X-Sqsd-Timestamp: 1696161600
+//	X-Sqsd-Signature: v1=105d14d03c9a719d819a918eee8bfe9be12128ca6826003095ea672324b55c0e
+//
+// Signature is hex encoded HMAC-SHA256 of "<timestamp>\n<message id>\n<body>" by shared secret,
+// where message id is the value of X_AWS_SQSD_MSGID header.
+// Worker should reject request whose timestamp is too old to prevent replay.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampHeader is the header name of signed time by unix seconds.
+	TimestampHeader = "X-Sqsd-Timestamp"
+	// SignatureHeader is the header name of signature.
+	SignatureHeader = "X-Sqsd-Signature"
+	// MessageIDHeader is the header name of message id, which is set by sqsd.
+	MessageIDHeader = "X_AWS_SQSD_MSGID"
+
+	version = "v1"
+)
+
+var (
+	// ErrMissingHeader shows that request doesn't have headers for signature.
+	ErrMissingHeader = errors.New("signature header is missing")
+	// ErrTimestampExpired shows that timestamp of request is out of tolerance.
+	ErrTimestampExpired = errors.New("signature timestamp is expired")
+	// ErrInvalidSignature shows that signature doesn't match any secret.
+	ErrInvalidSignature = errors.New("signature is invalid")
+)
+
+// Sign returns value of SignatureHeader.
+func Sign(secret []byte, timestamp time.Time, messageID string, body []byte) string {
+	return version + "=" + hex.EncodeToString(digest(secret, strconv.FormatInt(timestamp.Unix(), 10), messageID, body))
+}
+
+func digest(secret []byte, timestamp, messageID string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + messageID + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// SignRequest sets TimestampHeader and SignatureHeader to request.
+// body must be the same as body of request.
+func SignRequest(req *http.Request, secret []byte, now time.Time, body []byte) {
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, now, req.Header.Get(MessageIDHeader), body))
+}
+
+// Verifier verifies signed request.
+type Verifier struct {
+	secrets   [][]byte
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// VerifierOption is an option for Verifier.
+type VerifierOption func(*Verifier)
+
+// Tolerance sets allowed difference between timestamp of request and current time.
+// As default, 5 minutes is used.
+func Tolerance(d time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.tolerance = d
+	}
+}
+
+// PreviousSecret adds secret which is also accepted, for rotation of secret.
+func PreviousSecret(secret []byte) VerifierOption {
+	return func(v *Verifier) {
+		v.secrets = append(v.secrets, secret)
+	}
+}
+
+// NewVerifier returns Verifier by shared secret.
+func NewVerifier(secret []byte, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		secrets:   [][]byte{secret},
+		tolerance: 5 * time.Minute,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify verifies request and returns its body.
+// Body of request is replaced so that it can be read again.
+func (v *Verifier) Verify(r *http.Request) ([]byte, error) {
+	ts := r.Header.Get(TimestampHeader)
+	sig, ok := strings.CutPrefix(r.Header.Get(SignatureHeader), version+"=")
+	if ts == "" || !ok {
+		return nil, ErrMissingHeader
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrMissingHeader
+	}
+	if d := v.now().Sub(time.Unix(sec, 0)); d > v.tolerance || d < -v.tolerance {
+		return nil, ErrTimestampExpired
+	}
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	for _, secret := range v.secrets {
+		if hmac.Equal(expected, digest(secret, ts, r.Header.Get(MessageIDHeader), body)) {
+			return body, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// Middleware responds 401 to request which fails to be verified.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
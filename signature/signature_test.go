@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// same as: printf '1696161600\nid:1\n{"hello":"world"}' | openssl dgst -sha256 -hmac secret
+	sig := Sign([]byte("secret"), time.Unix(1696161600, 0), "id:1", []byte(`{"hello":"world"}`))
+	assert.Equal(t, "v1=105d14d03c9a719d819a918eee8bfe9be12128ca6826003095ea672324b55c0e", sig)
+}
+
+func TestVerifier(t *testing.T) {
+	now := time.Unix(1696161600, 0)
+	newRequest := func(secret string, signedAt time.Time) *http.Request {
+		body := `{"hello":"world"}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(MessageIDHeader, "id:1")
+		SignRequest(req, []byte(secret), signedAt, []byte(body))
+		return req
+	}
+
+	v := NewVerifier([]byte("secret"), PreviousSecret([]byte("old")), Tolerance(time.Minute))
+	v.now = func() time.Time { return now }
+
+	for _, tt := range []struct {
+		label   string
+		req     func() *http.Request
+		wantErr error
+	}{
+		{label: "valid", req: func() *http.Request { return newRequest("secret", now) }},
+		{label: "previous secret", req: func() *http.Request { return newRequest("old", now) }},
+		{label: "unknown secret", req: func() *http.Request { return newRequest("unknown", now) }, wantErr: ErrInvalidSignature},
+		{label: "expired", req: func() *http.Request { return newRequest("secret", now.Add(-2*time.Minute)) }, wantErr: ErrTimestampExpired},
+		{label: "future", req: func() *http.Request { return newRequest("secret", now.Add(2*time.Minute)) }, wantErr: ErrTimestampExpired},
+		{label: "missing", req: func() *http.Request {
+			req := newRequest("secret", now)
+			req.Header.Del(SignatureHeader)
+			return req
+		}, wantErr: ErrMissingHeader},
+		{label: "forged message id", req: func() *http.Request {
+			req := newRequest("secret", now)
+			req.Header.Set(MessageIDHeader, "id:2")
+			return req
+		}, wantErr: ErrInvalidSignature},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			req := tt.req()
+			body, err := v.Verify(req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, `{"hello":"world"}`, string(body))
+			b, _ := io.ReadAll(req.Body)
+			assert.Equal(t, body, b)
+		})
+	}
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("secret", now))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("unknown", now))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -23,6 +23,15 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MonitoringServiceClient interface {
 	CurrentWorkings(ctx context.Context, in *CurrentWorkingsRequest, opts ...grpc.CallOption) (*CurrentWorkingsResponse, error)
+	LockerHealth(ctx context.Context, in *LockerHealthRequest, opts ...grpc.CallOption) (*LockerHealthResponse, error)
+	ListLocks(ctx context.Context, in *ListLocksRequest, opts ...grpc.CallOption) (*ListLocksResponse, error)
+	GetLock(ctx context.Context, in *GetLockRequest, opts ...grpc.CallOption) (*GetLockResponse, error)
+	ReleaseLock(ctx context.Context, in *ReleaseLockRequest, opts ...grpc.CallOption) (*ReleaseLockResponse, error)
+	UnlockerStatus(ctx context.Context, in *UnlockerStatusRequest, opts ...grpc.CallOption) (*UnlockerStatusResponse, error)
+	InvokerEndpoints(ctx context.Context, in *InvokerEndpointsRequest, opts ...grpc.CallOption) (*InvokerEndpointsResponse, error)
+	WorkerStatus(ctx context.Context, in *WorkerStatusRequest, opts ...grpc.CallOption) (*WorkerStatusResponse, error)
+	Canary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*CanaryResponse, error)
+	SetCanaryWeight(ctx context.Context, in *SetCanaryWeightRequest, opts ...grpc.CallOption) (*CanaryResponse, error)
 }
 
 type monitoringServiceClient struct {
@@ -42,11 +51,101 @@ func (c *monitoringServiceClient) CurrentWorkings(ctx context.Context, in *Curre
 	return out, nil
 }
 
+func (c *monitoringServiceClient) LockerHealth(ctx context.Context, in *LockerHealthRequest, opts ...grpc.CallOption) (*LockerHealthResponse, error) {
+	out := new(LockerHealthResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/LockerHealth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) ListLocks(ctx context.Context, in *ListLocksRequest, opts ...grpc.CallOption) (*ListLocksResponse, error) {
+	out := new(ListLocksResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/ListLocks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) GetLock(ctx context.Context, in *GetLockRequest, opts ...grpc.CallOption) (*GetLockResponse, error) {
+	out := new(GetLockResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/GetLock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) ReleaseLock(ctx context.Context, in *ReleaseLockRequest, opts ...grpc.CallOption) (*ReleaseLockResponse, error) {
+	out := new(ReleaseLockResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/ReleaseLock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) UnlockerStatus(ctx context.Context, in *UnlockerStatusRequest, opts ...grpc.CallOption) (*UnlockerStatusResponse, error) {
+	out := new(UnlockerStatusResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/UnlockerStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) InvokerEndpoints(ctx context.Context, in *InvokerEndpointsRequest, opts ...grpc.CallOption) (*InvokerEndpointsResponse, error) {
+	out := new(InvokerEndpointsResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/InvokerEndpoints", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) WorkerStatus(ctx context.Context, in *WorkerStatusRequest, opts ...grpc.CallOption) (*WorkerStatusResponse, error) {
+	out := new(WorkerStatusResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/WorkerStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) Canary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*CanaryResponse, error) {
+	out := new(CanaryResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/Canary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *monitoringServiceClient) SetCanaryWeight(ctx context.Context, in *SetCanaryWeightRequest, opts ...grpc.CallOption) (*CanaryResponse, error) {
+	out := new(CanaryResponse)
+	err := c.cc.Invoke(ctx, "/sqsd.MonitoringService/SetCanaryWeight", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MonitoringServiceServer is the server API for MonitoringService service.
 // All implementations must embed UnimplementedMonitoringServiceServer
 // for forward compatibility
 type MonitoringServiceServer interface {
 	CurrentWorkings(context.Context, *CurrentWorkingsRequest) (*CurrentWorkingsResponse, error)
+	LockerHealth(context.Context, *LockerHealthRequest) (*LockerHealthResponse, error)
+	ListLocks(context.Context, *ListLocksRequest) (*ListLocksResponse, error)
+	GetLock(context.Context, *GetLockRequest) (*GetLockResponse, error)
+	ReleaseLock(context.Context, *ReleaseLockRequest) (*ReleaseLockResponse, error)
+	UnlockerStatus(context.Context, *UnlockerStatusRequest) (*UnlockerStatusResponse, error)
+	InvokerEndpoints(context.Context, *InvokerEndpointsRequest) (*InvokerEndpointsResponse, error)
+	WorkerStatus(context.Context, *WorkerStatusRequest) (*WorkerStatusResponse, error)
+	Canary(context.Context, *CanaryRequest) (*CanaryResponse, error)
+	SetCanaryWeight(context.Context, *SetCanaryWeightRequest) (*CanaryResponse, error)
 	mustEmbedUnimplementedMonitoringServiceServer()
 }
 
@@ -57,6 +156,33 @@ type UnimplementedMonitoringServiceServer struct {
 func (UnimplementedMonitoringServiceServer) CurrentWorkings(context.Context, *CurrentWorkingsRequest) (*CurrentWorkingsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CurrentWorkings not implemented")
 }
+func (UnimplementedMonitoringServiceServer) LockerHealth(context.Context, *LockerHealthRequest) (*LockerHealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LockerHealth not implemented")
+}
+func (UnimplementedMonitoringServiceServer) ListLocks(context.Context, *ListLocksRequest) (*ListLocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLocks not implemented")
+}
+func (UnimplementedMonitoringServiceServer) GetLock(context.Context, *GetLockRequest) (*GetLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLock not implemented")
+}
+func (UnimplementedMonitoringServiceServer) ReleaseLock(context.Context, *ReleaseLockRequest) (*ReleaseLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseLock not implemented")
+}
+func (UnimplementedMonitoringServiceServer) UnlockerStatus(context.Context, *UnlockerStatusRequest) (*UnlockerStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnlockerStatus not implemented")
+}
+func (UnimplementedMonitoringServiceServer) InvokerEndpoints(context.Context, *InvokerEndpointsRequest) (*InvokerEndpointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InvokerEndpoints not implemented")
+}
+func (UnimplementedMonitoringServiceServer) WorkerStatus(context.Context, *WorkerStatusRequest) (*WorkerStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WorkerStatus not implemented")
+}
+func (UnimplementedMonitoringServiceServer) Canary(context.Context, *CanaryRequest) (*CanaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Canary not implemented")
+}
+func (UnimplementedMonitoringServiceServer) SetCanaryWeight(context.Context, *SetCanaryWeightRequest) (*CanaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCanaryWeight not implemented")
+}
 func (UnimplementedMonitoringServiceServer) mustEmbedUnimplementedMonitoringServiceServer() {}
 
 // UnsafeMonitoringServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +214,168 @@ func _MonitoringService_CurrentWorkings_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MonitoringService_LockerHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LockerHealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).LockerHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/LockerHealth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).LockerHealth(ctx, req.(*LockerHealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_ListLocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).ListLocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/ListLocks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).ListLocks(ctx, req.(*ListLocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_GetLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).GetLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/GetLock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).GetLock(ctx, req.(*GetLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_ReleaseLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).ReleaseLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/ReleaseLock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).ReleaseLock(ctx, req.(*ReleaseLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_UnlockerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockerStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).UnlockerStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/UnlockerStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).UnlockerStatus(ctx, req.(*UnlockerStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_InvokerEndpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokerEndpointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).InvokerEndpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/InvokerEndpoints",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).InvokerEndpoints(ctx, req.(*InvokerEndpointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_WorkerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkerStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).WorkerStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/WorkerStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).WorkerStatus(ctx, req.(*WorkerStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_Canary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).Canary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/Canary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).Canary(ctx, req.(*CanaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MonitoringService_SetCanaryWeight_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCanaryWeightRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MonitoringServiceServer).SetCanaryWeight(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.MonitoringService/SetCanaryWeight",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MonitoringServiceServer).SetCanaryWeight(ctx, req.(*SetCanaryWeightRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MonitoringService_ServiceDesc is the grpc.ServiceDesc for MonitoringService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,6 +387,128 @@ var MonitoringService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CurrentWorkings",
 			Handler:    _MonitoringService_CurrentWorkings_Handler,
 		},
+		{
+			MethodName: "LockerHealth",
+			Handler:    _MonitoringService_LockerHealth_Handler,
+		},
+		{
+			MethodName: "ListLocks",
+			Handler:    _MonitoringService_ListLocks_Handler,
+		},
+		{
+			MethodName: "GetLock",
+			Handler:    _MonitoringService_GetLock_Handler,
+		},
+		{
+			MethodName: "ReleaseLock",
+			Handler:    _MonitoringService_ReleaseLock_Handler,
+		},
+		{
+			MethodName: "UnlockerStatus",
+			Handler:    _MonitoringService_UnlockerStatus_Handler,
+		},
+		{
+			MethodName: "InvokerEndpoints",
+			Handler:    _MonitoringService_InvokerEndpoints_Handler,
+		},
+		{
+			MethodName: "WorkerStatus",
+			Handler:    _MonitoringService_WorkerStatus_Handler,
+		},
+		{
+			MethodName: "Canary",
+			Handler:    _MonitoringService_Canary_Handler,
+		},
+		{
+			MethodName: "SetCanaryWeight",
+			Handler:    _MonitoringService_SetCanaryWeight_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sqsd.proto",
+}
+
+// WorkerClient is the client API for Worker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WorkerClient interface {
+	Process(ctx context.Context, in *Job, opts ...grpc.CallOption) (*Result, error)
+}
+
+type workerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWorkerClient(cc grpc.ClientConnInterface) WorkerClient {
+	return &workerClient{cc}
+}
+
+func (c *workerClient) Process(ctx context.Context, in *Job, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, "/sqsd.Worker/Process", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WorkerServer is the server API for Worker service.
+// All implementations must embed UnimplementedWorkerServer
+// for forward compatibility
+type WorkerServer interface {
+	Process(context.Context, *Job) (*Result, error)
+	mustEmbedUnimplementedWorkerServer()
+}
+
+// UnimplementedWorkerServer must be embedded to have forward compatible implementations.
+type UnimplementedWorkerServer struct {
+}
+
+func (UnimplementedWorkerServer) Process(context.Context, *Job) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Process not implemented")
+}
+func (UnimplementedWorkerServer) mustEmbedUnimplementedWorkerServer() {}
+
+// UnsafeWorkerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WorkerServer will
+// result in compilation errors.
+type UnsafeWorkerServer interface {
+	mustEmbedUnimplementedWorkerServer()
+}
+
+func RegisterWorkerServer(s grpc.ServiceRegistrar, srv WorkerServer) {
+	s.RegisterService(&Worker_ServiceDesc, srv)
+}
+
+func _Worker_Process_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Job)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Process(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqsd.Worker/Process",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Process(ctx, req.(*Job))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Worker_ServiceDesc is the grpc.ServiceDesc for Worker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Worker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sqsd.Worker",
+	HandlerType: (*WorkerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Process",
+			Handler:    _Worker_Process_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "sqsd.proto",
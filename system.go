@@ -2,10 +2,13 @@ package sqsd
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/taiyoh/sqsd/locker"
 )
 
 // DisableMonitoring makes gRPC server disable to run.
@@ -17,6 +20,11 @@ type System struct {
 	port     int
 	capacity int
 	invoker  Invoker
+	mws      []Middleware
+	unlocker *locker.Unlocker
+
+	batchSize   int
+	batchWindow time.Duration
 }
 
 // SystemBuilder provides constructor for system object requirements.
@@ -30,10 +38,23 @@ func GatewayBuilder(queue *sqs.SQS, queueURL string, parallel int, timeout time.
 }
 
 // ConsumerBuilder builds consumer for system.
-func ConsumerBuilder(invoker Invoker, parallel int) SystemBuilder {
+// invoker is wrapped by middlewares in the order of Chain.
+func ConsumerBuilder(invoker Invoker, parallel int, mws ...Middleware) SystemBuilder {
 	return func(s *System) {
 		s.capacity = parallel
 		s.invoker = invoker
+		s.mws = mws
+	}
+}
+
+// BatchBuilder makes consumer invoke worker with up to size messages,
+// or messages which arrive within window after first message of batch.
+// invoker of ConsumerBuilder must implement BatchInvoker, and middlewares are not used.
+// size 1 or less disables batch invocation.
+func BatchBuilder(size int, window time.Duration) SystemBuilder {
+	return func(s *System) {
+		s.batchSize = size
+		s.batchWindow = window
 	}
 }
 
@@ -44,6 +65,14 @@ func MonitorBuilder(port int) SystemBuilder {
 	}
 }
 
+// UnlockerBuilder sets unlocker to system.
+// unlocker runs with system and its status is reported by monitor server.
+func UnlockerBuilder(u *locker.Unlocker) SystemBuilder {
+	return func(s *System) {
+		s.unlocker = u
+	}
+}
+
 // NewSystem returns System object.
 func NewSystem(builders ...SystemBuilder) *System {
 	sys := &System{
@@ -58,9 +87,27 @@ func NewSystem(builders ...SystemBuilder) *System {
 // Run starts running actors and gRPC server.
 func (s *System) Run(ctx context.Context) error {
 	msgsCh := make(chan Message, s.capacity)
-	worker := startWorker(ctx, s.invoker, msgsCh, s.gateway)
+	var worker *worker
+	if s.batchSize > 1 {
+		bi, ok := s.invoker.(BatchInvoker)
+		if !ok {
+			return errors.New("invoker doesn't support batch invocation")
+		}
+		worker = startBatchWorker(ctx, bi, s.batchSize, s.batchWindow, msgsCh, s.gateway)
+	} else {
+		worker = startWorker(ctx, Chain(s.invoker, s.mws...), msgsCh, s.gateway)
+	}
 
 	monitor := NewMonitoringService(worker)
+	monitor.locker = s.gateway.locker
+	monitor.unlocker = s.unlocker
+	// invokers are searched through wrappers such as ShadowInvoker and RoutingInvoker.
+	if r, ok := findInvoker[EndpointReporter](s.invoker); ok {
+		monitor.balancer = r
+	}
+	if c, ok := findInvoker[CanaryController](s.invoker); ok {
+		monitor.canary = c
+	}
 
 	if s.port >= 0 {
 		grpcServer, err := newGRPCServer(monitor, s.port)
@@ -79,6 +126,14 @@ func (s *System) Run(ctx context.Context) error {
 		s.gateway.start(ctx, msgsCh)
 	}()
 
+	if s.unlocker != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.unlocker.Run(ctx)
+		}()
+	}
+
 	<-ctx.Done()
 	getLogger().Info("signal caught. stopping worker...")
 
@@ -51,3 +51,12 @@ func TestSystem(t *testing.T) {
 
 	assert.NoError(t, <-errCh)
 }
+
+func TestSystemBatchRequiresBatchInvoker(t *testing.T) {
+	sys := NewSystem(
+		GatewayBuilder(nil, "", 1, time.Second),
+		ConsumerBuilder(InvokerFunc(func(ctx context.Context, q Message) error { return nil }), 1),
+		BatchBuilder(10, time.Second),
+	)
+	assert.EqualError(t, sys.Run(context.Background()), "invoker doesn't support batch invocation")
+}
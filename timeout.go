@@ -0,0 +1,95 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeadlineHeader is the header which has absolute deadline of invocation in RFC3339 format,
+// so that worker can abort its work before sqsd gives it up.
+const DeadlineHeader = "X-Sqsd-Deadline"
+
+// isTimeout reports whether invocation failed because its deadline was exceeded.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, os.ErrDeadlineExceeded) ||
+		status.Code(err) == codes.DeadlineExceeded
+}
+
+// withInvocationTimeout returns context whose deadline is the earlier of ctx's and d later.
+// d 0 or less means no timeout except for ctx's.
+func withInvocationTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// formatDeadline returns deadline of ctx formatted for worker. ok is false if ctx has no deadline.
+func formatDeadline(ctx context.Context) (deadline string, ok bool) {
+	t, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+	return t.UTC().Format(time.RFC3339Nano), true
+}
+
+// setDeadlineHeader sets DeadlineHeader by deadline of ctx.
+func setDeadlineHeader(ctx context.Context, h http.Header) {
+	if deadline, ok := formatDeadline(ctx); ok {
+		h.Set(DeadlineHeader, deadline)
+	}
+}
+
+// parseTimeout parses timeout such as "1m30s", or "90" as seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// TimeoutAttribute returns Middleware which cancels context of invocation by timeout in message attribute name,
+// such as "1m30s", or "90" as seconds. Timeout only shortens deadline, so that it never exceeds timeout of invoker.
+// Message without valid attribute is invoked as it is.
+func TimeoutAttribute(name string) Middleware {
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			v, ok := q.Attributes[name]
+			if !ok {
+				return next.Invoke(ctx, q)
+			}
+			d, err := parseTimeout(v)
+			if err != nil || d <= 0 {
+				getLogger().Warn("invalid timeout attribute is ignored", "message_id", q.ID, "attribute", name, "value", v)
+				return next.Invoke(ctx, q)
+			}
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Invoke(ctx, q)
+		})
+	}
+}
+
+// VisibilityDeadline returns Middleware which cancels context of invocation margin before message becomes visible again,
+// so that invocation ends before message is redelivered to another consumer.
+// Message whose VisibleAt is unknown is invoked as it is.
+func VisibilityDeadline(margin time.Duration) Middleware {
+	return func(next Invoker) Invoker {
+		return InvokerFunc(func(ctx context.Context, q Message) error {
+			if q.VisibleAt.IsZero() {
+				return next.Invoke(ctx, q)
+			}
+			ctx, cancel := context.WithDeadline(ctx, q.VisibleAt.Add(-margin))
+			defer cancel()
+			return next.Invoke(ctx, q)
+		})
+	}
+}
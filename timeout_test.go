@@ -0,0 +1,70 @@
+package sqsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func deadlineOf(ctx context.Context) time.Duration {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return time.Until(d).Round(time.Second)
+}
+
+func TestTimeoutAttribute(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		remaining <- deadlineOf(ctx)
+		return nil
+	}), TimeoutAttribute("Timeout"))
+
+	for v, expected := range map[string]time.Duration{
+		"10":    10 * time.Second,
+		"1m30s": 90 * time.Second,
+		"abc":   0,
+		"-1":    0,
+	} {
+		assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", Attributes: map[string]string{"Timeout": v}}))
+		assert.Equal(t, expected, <-remaining, v)
+	}
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}))
+	assert.Equal(t, time.Duration(0), <-remaining)
+
+	// timeout never extends deadline of parent context.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, ivk.Invoke(ctx, Message{ID: "id:1", Attributes: map[string]string{"Timeout": "1m"}}))
+	assert.Equal(t, 5*time.Second, <-remaining)
+}
+
+func TestVisibilityDeadline(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	ivk := Chain(InvokerFunc(func(ctx context.Context, q Message) error {
+		remaining <- deadlineOf(ctx)
+		return nil
+	}), VisibilityDeadline(5*time.Second))
+
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1", VisibleAt: time.Now().Add(time.Minute)}))
+	assert.Equal(t, 55*time.Second, <-remaining)
+	assert.NoError(t, ivk.Invoke(context.Background(), Message{ID: "id:1"}))
+	assert.Equal(t, time.Duration(0), <-remaining)
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.True(t, isTimeout(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)))
+	assert.True(t, isTimeout(fmt.Errorf("read: %w", os.ErrDeadlineExceeded)))
+	assert.True(t, isTimeout(status.Error(codes.DeadlineExceeded, "deadline")))
+	assert.False(t, isTimeout(context.Canceled))
+	assert.False(t, isTimeout(errors.New("failure response: 500")))
+	assert.Equal(t, "timeout", outcomeOf(context.DeadlineExceeded))
+}